@@ -0,0 +1,103 @@
+package casperjs
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Artifact is a file produced by a CasperJS run, such as a casper.capture() screenshot
+type Artifact struct {
+	// Path of the file, including the artifacts directory of the run
+	Path string
+	// Kind is one of "screenshot", "html", "log", "diff" or "other"
+	Kind string
+	Size int64
+}
+
+var unsafeDirCharsRegex = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// NewArtifactsRunDir creates the timestamped directory holding the artifacts of a run
+// started at the given moment, under the artifacts root folder. Runs started within the
+// same second get a numbered suffix, so that no run ever writes into the directory of another.
+func NewArtifactsRunDir(root string, startedAt time.Time) (string, error) {
+
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return "", err
+	}
+
+	stamp := startedAt.Format("20060102-150405")
+	for n := 1; ; n++ {
+		dir := filepath.Join(root, stamp)
+		if n > 1 {
+			dir += "-" + strconv.Itoa(n)
+		}
+
+		err := os.Mkdir(dir, 0755)
+		if err == nil {
+			return dir, nil
+		}
+		if !os.IsExist(err) {
+			return "", err
+		}
+	}
+}
+
+// prepareArtifactDir creates the directory the given attempt of the test runs in,
+// so that relative casper.capture() filenames land there. The first attempt uses
+// <run>/<test key>, and retries use <run>/<test key>.attempt-<n>.
+func (c *CasperTest) prepareArtifactDir(options *RunOptions, attempt int) (string, error) {
+
+	dir := filepath.Join(options.ArtifactsDir, c.attemptDirName(attempt))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// attemptDirName returns the name of the directory the given attempt of the test runs in
+func (c *CasperTest) attemptDirName(attempt int) string {
+
+	if attempt > 1 {
+		return c.dirName() + ".attempt-" + strconv.Itoa(attempt)
+	}
+	return c.dirName()
+}
+
+// dirName turns the test key into a name that is safe to use as a directory
+func (c *CasperTest) dirName() string {
+	return strings.Trim(unsafeDirCharsRegex.ReplaceAllString(c.Key(), "_"), "_")
+}
+
+// indexArtifacts lists every file produced under dir
+func indexArtifacts(dir string) ([]*Artifact, error) {
+
+	artifacts := make([]*Artifact, 0)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			artifacts = append(artifacts, &Artifact{Path: path, Kind: artifactKind(path), Size: info.Size()})
+		}
+		return nil
+	})
+	return artifacts, err
+}
+
+// artifactKind classifies an artifact by its file extension
+func artifactKind(path string) string {
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".png", ".jpg", ".jpeg", ".gif", ".bmp":
+		return "screenshot"
+	case ".html", ".htm":
+		return "html"
+	case ".log", ".txt", ".json":
+		return "log"
+	}
+	return "other"
+}
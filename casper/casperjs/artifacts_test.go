@@ -0,0 +1,24 @@
+package casperjs
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNewArtifactsRunDir(t *testing.T) {
+
+	root := filepath.Join(t.TempDir(), "artifacts")
+	startedAt := time.Date(2026, 1, 2, 15, 4, 5, 0, time.Local)
+
+	want := []string{"20260102-150405", "20260102-150405-2", "20260102-150405-3"}
+	for _, name := range want {
+		dir, err := NewArtifactsRunDir(root, startedAt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if dir != filepath.Join(root, name) {
+			t.Errorf("got directory %s, want %s", dir, name)
+		}
+	}
+}
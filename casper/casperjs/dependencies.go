@@ -0,0 +1,171 @@
+package casperjs
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// dependencyGraph orders the tests of a run according to their MANIFEST_SCRIPT_DEPENDS.
+// Within a matrix, a test depends on its prerequisites in the same cell.
+type dependencyGraph struct {
+	// prerequisites and dependents hold the edges of the graph, in both directions
+	prerequisites map[*CasperTest][]*CasperTest
+	dependents    map[*CasperTest][]*CasperTest
+	// pending counts the prerequisites of every test that have not completed yet
+	pending map[*CasperTest]int
+	// skipped holds the tests skipped because one of their prerequisites did not pass
+	skipped map[*CasperTest]bool
+}
+
+// newDependencyGraph links every test to the prerequisites that are part of the run.
+// Prerequisites left out of the run, e.g. by a tag filter, are assumed to be satisfied.
+// An error is returned if the dependencies form a cycle.
+func newDependencyGraph(tests []*CasperTest) (*dependencyGraph, error) {
+
+	g := &dependencyGraph{
+		prerequisites: make(map[*CasperTest][]*CasperTest),
+		dependents:    make(map[*CasperTest][]*CasperTest),
+		pending:       make(map[*CasperTest]int),
+		skipped:       make(map[*CasperTest]bool),
+	}
+
+	byKey := make(map[string]*CasperTest)
+	for _, t := range tests {
+		byKey[t.Key()] = t
+	}
+
+	keys := make([]string, 0, len(tests))
+	edges := make(map[string][]string)
+	for _, t := range tests {
+		keys = append(keys, t.Key())
+		for _, id := range t.Depends {
+			prerequisite := &CasperTest{Id: id, Cell: t.Cell}
+			p, found := byKey[prerequisite.Key()]
+			if !found {
+				log.Printf("Casper test %s depends on %s, which is not part of this run", t.Key(), prerequisite.Key())
+				continue
+			}
+			g.prerequisites[t] = append(g.prerequisites[t], p)
+			g.dependents[p] = append(g.dependents[p], t)
+			g.pending[t]++
+			edges[t.Key()] = append(edges[t.Key()], p.Key())
+		}
+	}
+
+	if cycle := findDependencyCycle(keys, edges); cycle != nil {
+		return nil, fmt.Errorf("dependency cycle: %s", strings.Join(cycle, " -> "))
+	}
+	return g, nil
+}
+
+// roots returns the tests without pending prerequisites, in their original order
+func (g *dependencyGraph) roots(tests []*CasperTest) []*CasperTest {
+
+	ready := make([]*CasperTest, 0)
+	for _, t := range tests {
+		if g.pending[t] == 0 {
+			ready = append(ready, t)
+		}
+	}
+	return ready
+}
+
+// complete records that the test has finished, and returns the dependents that are now
+// ready to run. When the test did not succeed, its dependents, and theirs in turn, are
+// not run: they are given a skipped result instead, and returned as skipped.
+func (g *dependencyGraph) complete(t *CasperTest) (ready []*CasperTest, skipped []*CasperTest) {
+
+	succeeded := t.Result != nil && (t.Result.Status == StatusPassed || t.Result.Status == StatusFlaky)
+
+	for _, dependent := range g.dependents[t] {
+		if g.skipped[dependent] {
+			// Already skipped because of another prerequisite
+			continue
+		}
+
+		if !succeeded {
+			status := StatusUnknown
+			if t.Result != nil {
+				status = t.Result.Status
+			}
+			dependent.skip(fmt.Sprintf("prerequisite %s did not pass (%s)", t.Key(), status))
+			g.skipped[dependent] = true
+			skipped = append(skipped, dependent)
+
+			// The dependents of the skipped test cannot run either
+			_, transitive := g.complete(dependent)
+			skipped = append(skipped, transitive...)
+			continue
+		}
+
+		g.pending[dependent]--
+		if g.pending[dependent] == 0 {
+			ready = append(ready, dependent)
+		}
+	}
+	return ready, skipped
+}
+
+// skip gives the test a skipped result, for the given reason, without running it
+func (c *CasperTest) skip(reason string) {
+
+	log.Printf("Skipping Casper test %s: %s", c.Key(), reason)
+	now := time.Now()
+	c.Result = &TestResult{
+		Status:     StatusSkipped,
+		SkipReason: reason,
+		StartedAt:  now,
+		FinishedAt: now,
+	}
+}
+
+// findDependencyCycle returns a cycle of the graph whose edges go from every key to its
+// prerequisites, starting and ending with the same key, or nil if the graph is acyclic
+func findDependencyCycle(keys []string, edges map[string][]string) []string {
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int)
+	path := make([]string, 0)
+
+	var visit func(key string) []string
+	visit = func(key string) []string {
+
+		switch state[key] {
+		case visited:
+			return nil
+		case visiting:
+			for i, k := range path {
+				if k == key {
+					return append(append([]string{}, path[i:]...), key)
+				}
+			}
+		}
+
+		state[key] = visiting
+		path = append(path, key)
+		for _, prerequisite := range edges[key] {
+			if cycle := visit(prerequisite); cycle != nil {
+				return cycle
+			}
+		}
+		path = path[:len(path)-1]
+		state[key] = visited
+		return nil
+	}
+
+	sorted := append([]string{}, keys...)
+	sort.Strings(sorted)
+	for _, key := range sorted {
+		if cycle := visit(key); cycle != nil {
+			return cycle
+		}
+	}
+	return nil
+}
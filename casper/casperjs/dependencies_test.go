@@ -0,0 +1,90 @@
+package casperjs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFindDependencyCycle(t *testing.T) {
+
+	cases := []struct {
+		name  string
+		keys  []string
+		edges map[string][]string
+		want  []string
+	}{
+		{"no edges", []string{"a", "b"}, nil, nil},
+		{"chain", []string{"a", "b", "c"}, map[string][]string{"b": {"a"}, "c": {"b"}}, nil},
+		{"diamond", []string{"a", "b", "c", "d"}, map[string][]string{"b": {"a"}, "c": {"a"}, "d": {"b", "c"}}, nil},
+		{"self", []string{"a"}, map[string][]string{"a": {"a"}}, []string{"a", "a"}},
+		{"pair", []string{"a", "b"}, map[string][]string{"a": {"b"}, "b": {"a"}}, []string{"a", "b", "a"}},
+		{"behind a chain", []string{"a", "b", "c", "d"}, map[string][]string{"a": {"b"}, "b": {"c"}, "c": {"d"}, "d": {"b"}},
+			[]string{"b", "c", "d", "b"}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := findDependencyCycle(tc.keys, tc.edges); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got cycle %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDependencyGraphComplete(t *testing.T) {
+
+	a := &CasperTest{Id: "a"}
+	b := &CasperTest{Id: "b", Depends: []string{"a"}}
+	c := &CasperTest{Id: "c", Depends: []string{"a", "b"}}
+	d := &CasperTest{Id: "d", Depends: []string{"missing"}}
+	tests := []*CasperTest{a, b, c, d}
+
+	graph, err := newDependencyGraph(tests)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if roots := graph.roots(tests); !reflect.DeepEqual(roots, []*CasperTest{a, d}) {
+		t.Errorf("got %d roots, want a and d, whose prerequisite is not part of the run", len(roots))
+	}
+
+	// c still waits for b once a passed
+	a.Result = &TestResult{Status: StatusPassed}
+	ready, skipped := graph.complete(a)
+	if !reflect.DeepEqual(ready, []*CasperTest{b}) || len(skipped) != 0 {
+		t.Errorf("got %d ready and %d skipped after a passed, want b ready", len(ready), len(skipped))
+	}
+
+	// A failed b skips c
+	b.Result = &TestResult{Status: StatusFailed}
+	ready, skipped = graph.complete(b)
+	if len(ready) != 0 || !reflect.DeepEqual(skipped, []*CasperTest{c}) {
+		t.Errorf("got %d ready and %d skipped after b failed, want c skipped", len(ready), len(skipped))
+	}
+	if c.Result == nil || c.Result.Status != StatusSkipped || c.Result.SkipReason != "prerequisite b did not pass (failed)" {
+		t.Errorf("got result %+v for c, want it skipped because of b", c.Result)
+	}
+}
+
+func TestDependencyGraphMatrixCells(t *testing.T) {
+
+	matrix := &Matrix{Axes: []*MatrixAxis{{Name: "env", Values: []*MatrixValue{{Label: "dev"}, {Label: "prod"}}}}}
+	tests := ExpandMatrix([]*CasperTest{{Id: "a"}, {Id: "b", Depends: []string{"a"}}}, matrix)
+
+	graph, err := newDependencyGraph(tests)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every cell of b depends on a in the same cell only
+	for _, dependent := range tests[2:] {
+		prerequisites := graph.prerequisites[dependent]
+		if len(prerequisites) != 1 || prerequisites[0].Cell.Key != dependent.Cell.Key {
+			t.Errorf("%s: got %d prerequisites, want a in the same cell", dependent.Key(), len(prerequisites))
+		}
+	}
+
+	cyclic := []*CasperTest{{Id: "a", Depends: []string{"b"}}, {Id: "b", Depends: []string{"a"}}}
+	if _, err := newDependencyGraph(cyclic); err == nil {
+		t.Error("got no error for a dependency cycle")
+	}
+}
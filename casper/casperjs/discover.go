@@ -0,0 +1,274 @@
+// Package casperjs discovers CasperJS test scripts through their manifest variables,
+// runs them through casperjs, and turns their output into structured results and reports.
+// The casper command is a thin wrapper around it:
+//
+//	tests := casperjs.Discover(ctx, "./samples", nil)
+//	casperjs.RunTests(ctx, tests, &casperjs.RunOptions{Parallelism: 2})
+//	for _, t := range tests {
+//		// Tests not started before ctx was done have no result
+//		if t.Result != nil {
+//			fmt.Println(t.Key(), t.Result.Status)
+//		}
+//	}
+//
+// To run the tests across environments, expand them with ExpandMatrix before RunTests.
+package casperjs
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kr/fs"
+	"github.com/robertkrimen/otto/ast"
+	"github.com/robertkrimen/otto/parser"
+)
+
+// Discover traverses the files in the specified scriptFolder, and searches
+// for the manifest-specific Javascript variables. If all the required variables are found,
+// the file is assumed to contain a valid Casper TestSuite, ready to be run, unless
+// the selection leaves it out. A nil selection keeps every test. The traversal stops
+// early once ctx is done, returning the tests found so far.
+func Discover(ctx context.Context, scriptFolder string, selection *Selection) []*CasperTest {
+
+	testsToRun := make([]*CasperTest, 0)
+
+	WalkScripts(ctx, scriptFolder, selection, func(pathToFile string, info os.FileInfo) {
+
+		// Analyze the file and add it to the test suites collection
+		// if it contains the required info
+		testScript, ok := loadSelectedScript(pathToFile, selection)
+		if ok {
+			log.Println("Adding valid Casper test: ", testScript.Name)
+			testsToRun = append(testsToRun, testScript)
+		}
+	})
+
+	return testsToRun
+}
+
+// WalkScripts calls visit for every .js file under scriptFolder that is kept
+// by the path globs of the selection, which may be nil. Excluded folders are not
+// descended into. The walk stops once ctx is done.
+func WalkScripts(ctx context.Context, scriptFolder string, selection *Selection, visit func(pathToFile string, info os.FileInfo)) {
+
+	walker := fs.Walk(scriptFolder)
+	for walker.Step() {
+		if ctx.Err() != nil {
+			log.Println("Stopped walking ", scriptFolder, ": ", ctx.Err())
+			return
+		}
+
+		if err := walker.Err(); err != nil {
+			log.Println("Filesystem walker error: ", err)
+			continue
+		}
+
+		isDir := walker.Stat().IsDir()
+		isScript := !isDir && strings.HasSuffix(strings.ToLower(walker.Path()), ".js")
+
+		// Apply the path globs to folders and scripts only, skipping excluded folders entirely
+		relPath, err := filepath.Rel(scriptFolder, walker.Path())
+		if err == nil && relPath != "." && (isDir || isScript) {
+			if reason := selection.skipPathReason(relPath, isDir); reason != "" {
+				log.Printf("Skipping %s: %s", walker.Path(), reason)
+				if isDir {
+					walker.SkipDir()
+				}
+				continue
+			}
+		}
+
+		// Filter out directories and files without a .js extension
+		if isScript {
+			visit(walker.Path(), walker.Stat())
+		}
+	}
+}
+
+// loadSelectedScript loads the Casper test at pathToFile, and only returns it
+// along with ok set to true if the selection keeps it
+func loadSelectedScript(pathToFile string, selection *Selection) (*CasperTest, bool) {
+
+	testScript, err := LoadScript(pathToFile)
+	if err != nil {
+		log.Println("Not a valid Casper test: ", err)
+		return nil, false
+	}
+
+	if reason := selection.SkipReason(testScript); reason != "" {
+		log.Printf("Skipping Casper test %s: %s", testScript.Name, reason)
+		return nil, false
+	}
+
+	return testScript, true
+}
+
+// LoadScript reads the contents of a file at the given pathToFile path, and attempts
+// to parse the contents as Javascript, and to find a set of agreed-upon manifest variables.
+// If those are successfully retrieved, a CasperTest is returned, otherwise an error
+// describing what is missing or invalid.
+func LoadScript(pathToFile string) (*CasperTest, error) {
+
+	casperTest := &CasperTest{}
+
+	file, err := os.Open(pathToFile)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	fileContents := bytes.Buffer{}
+
+	// Superficial and preliminary vetting of the file contents while reading it,
+	// to make sure that it contains the designated manifest variables
+	var manifestTokenCount = 0
+	var outstandingTokenCount = int(math.Pow(2, float64(len(ManifestVariables)))) - 1
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		currentLine := scanner.Bytes()
+		currentLine = append(currentLine, '\n') // Add back the newline that scanner.Scan "stole away"
+
+		if _, writeErr := fileContents.Write(currentLine); writeErr != nil {
+			log.Println("Error writing file contents: ", writeErr)
+			continue
+		}
+
+		// The rest of the file is still kept, since optional manifest variables
+		// may be declared after the required ones
+		if manifestTokenCount >= outstandingTokenCount {
+			continue
+		}
+
+		// A MANIFEST object literal stands for all of the required variables at once
+		if objectManifestRegex.Match(currentLine) {
+			log.Printf("Found %s object literal", ObjectManifestVariable)
+			manifestTokenCount = outstandingTokenCount
+			continue
+		}
+
+		for i, curManifestVar := range ManifestVariables {
+			byteFlagPos := int(math.Pow(2, float64(i)))
+			if bytes.Contains(currentLine, []byte(curManifestVar)) &&
+				manifestTokenCount&byteFlagPos != byteFlagPos {
+
+				manifestTokenCount = manifestTokenCount + byteFlagPos
+				log.Printf("Found %s at index %d (byte flag pos %b), current bitmask map: %d (%b)",
+					curManifestVar, i, byteFlagPos, manifestTokenCount, manifestTokenCount)
+			}
+		}
+	}
+
+	if manifestTokenCount < outstandingTokenCount {
+		return nil, fmt.Errorf("%s: incomplete manifest definition", pathToFile)
+	}
+
+	if err := scanner.Err(); err != nil {
+		log.Println("Scanner error: ", err)
+	}
+
+	// try to parse the javascript and obtain the values
+	program, err := parser.ParseFile(nil, "", fileContents.String(), 0)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", pathToFile, err)
+	}
+
+	// Manifest values that are not plain literals get evaluated, e.g. PRODUCT + " checkout"
+	evaluator := newManifestEvaluator(program)
+
+	for _, declaration := range program.DeclarationList {
+
+		// Only care about variables
+		varDecl, ok := declaration.(*ast.VariableDeclaration)
+		if ok {
+
+			for _, varExpr := range varDecl.List {
+
+				variableName := varExpr.Name
+
+				if variableName == ObjectManifestVariable {
+					properties, err := objectManifestProperties(varExpr.Initializer)
+					if err != nil {
+						log.Println("Invalid manifest in ", pathToFile, ": ", err)
+					}
+
+					for _, property := range properties {
+						variableValue, err := evaluator.expressionValue(property.Value)
+						if err != nil {
+							log.Println("Cannot resolve ", ObjectManifestVariable, ".", property.Key, " in ", pathToFile, ": ", err)
+							continue
+						}
+						if err := casperTest.SetManifestVariable(property.ManifestVar, variableValue); err != nil {
+							return nil, fmt.Errorf("%s: invalid manifest value: %s", pathToFile, err)
+						}
+					}
+					continue
+				}
+
+				for i, curManifestVar := range ManifestVariables {
+					if variableName == string(curManifestVar) {
+
+						// Get the value
+						variableValue, err := evaluator.value(varExpr)
+						if err != nil {
+							log.Println("Cannot resolve ", curManifestVar, " in ", pathToFile, ": ", err)
+							continue
+						}
+						casperTest.SetPropertyByIndex(i, variableValue)
+					}
+				}
+
+				for _, curManifestVar := range OptionalManifestVariables {
+					if variableName == string(curManifestVar) {
+
+						variableValue, err := evaluator.value(varExpr)
+						if err != nil {
+							log.Println("Cannot resolve ", curManifestVar, " in ", pathToFile, ": ", err)
+							continue
+						}
+						if err := casperTest.SetOptionalProperty(curManifestVar, variableValue); err != nil {
+							return nil, fmt.Errorf("%s: invalid manifest value: %s", pathToFile, err)
+						}
+					}
+				}
+			}
+		}
+	}
+
+	if casperTest.Id == "" || casperTest.Name == "" {
+		return nil, fmt.Errorf("%s: incomplete manifest definition, the id and name must not be empty", pathToFile)
+	}
+
+	casperTest.FilePath = pathToFile
+	return casperTest, nil
+}
+
+// literalValue returns the textual value of a string or number literal expression.
+// Array literals are accepted as long as all their elements are literals, and
+// their values are returned as a comma-separated list.
+func literalValue(expr ast.Expression) (string, bool) {
+
+	switch literal := expr.(type) {
+	case *ast.StringLiteral:
+		return literal.Value, true
+	case *ast.NumberLiteral:
+		return literal.Literal, true
+	case *ast.ArrayLiteral:
+		values := make([]string, 0, len(literal.Value))
+		for _, element := range literal.Value {
+			value, ok := literalValue(element)
+			if !ok {
+				return "", false
+			}
+			values = append(values, value)
+		}
+		return strings.Join(values, ","), true
+	}
+	return "", false
+}
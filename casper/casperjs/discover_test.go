@@ -0,0 +1,82 @@
+package casperjs
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestLoadScript(t *testing.T) {
+
+	dir := writeFiles(t, map[string]string{
+		"checkout.js":   validScript,
+		"incomplete.js": `var MANIFEST_SCRIPT_ID = "a";`,
+		"object.js":     `var MANIFEST = {id: "home", name: "Home", description: "Opens the home page", retries: 2};`,
+		"computed.js": `var PRODUCT = "Shop";
+var MANIFEST_SCRIPT_ID = PRODUCT.toLowerCase() + "-home";
+var MANIFEST_SCRIPT_NAME = PRODUCT + " home";
+var MANIFEST_SCRIPT_DESC = "Opens the " + MANIFEST_SCRIPT_NAME;`,
+		"invalid.js": validScript + `var MANIFEST_SCRIPT_RETRIES = "often";`,
+	})
+
+	c, err := LoadScript(filepath.Join(dir, "checkout.js"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Id != "checkout" || c.Name != "Checkout" || c.Description != "Buys a product" {
+		t.Errorf("got manifest %q, %q, %q", c.Id, c.Name, c.Description)
+	}
+	if len(c.Tags) != 2 || !c.HasTag("payments") || c.Timeout.Seconds() != 30 {
+		t.Errorf("got tags %q and timeout %s", c.Tags, c.Timeout)
+	}
+
+	c, err = LoadScript(filepath.Join(dir, "computed.js"))
+	if err != nil || c.Id != "shop-home" || c.Description != "Opens the Shop home" {
+		t.Errorf("got test %+v for computed manifest values", c)
+	}
+
+	c, err = LoadScript(filepath.Join(dir, "object.js"))
+	if err != nil || c.Id != "home" || c.Name != "Home" || c.Description != "Opens the home page" || c.Retries == nil || *c.Retries != 2 {
+		t.Errorf("got test %+v for a MANIFEST object", c)
+	}
+
+	for _, name := range []string{"incomplete.js", "invalid.js", "missing.js"} {
+		if _, err := LoadScript(filepath.Join(dir, name)); err == nil {
+			t.Errorf("%s: got a test, want an error", name)
+		}
+	}
+}
+
+func TestDiscover(t *testing.T) {
+
+	script := func(id string, tags string) string {
+		return `var MANIFEST = {id: "` + id + `", name: "` + id + `", desc: "` + id + `", tags: [` + tags + `]};`
+	}
+	dir := writeFiles(t, map[string]string{
+		"home.js":            script("home", `"smoke"`),
+		"shop/checkout.js":   script("checkout", `"smoke", "payments"`),
+		"shop/search.js":     script("search", ""),
+		"drafts/wip.js":      script("wip", `"smoke"`),
+		"helpers/library.js": "function helper() {}",
+		"README.md":          "MANIFEST_SCRIPT_ID",
+	})
+	selection := &Selection{Tags: []string{"smoke"}, Exclude: []string{"drafts"}}
+
+	tests := Discover(context.Background(), dir, selection)
+	ids := make([]string, 0, len(tests))
+	for _, c := range tests {
+		ids = append(ids, c.Id)
+	}
+	sort.Strings(ids)
+	if want := []string{"checkout", "home"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("got tests %q, want %q", ids, want)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if tests := Discover(ctx, dir, nil); len(tests) != 0 {
+		t.Errorf("got %d tests once the context was done, want none", len(tests))
+	}
+}
@@ -0,0 +1,225 @@
+package casperjs
+
+import (
+	"errors"
+	"time"
+
+	"github.com/robertkrimen/otto"
+	"github.com/robertkrimen/otto/ast"
+	"github.com/robertkrimen/otto/token"
+)
+
+// Methods that may be called while evaluating a manifest value. They are pure
+// string and array operations, so that joins such as [PRODUCT, "checkout"].join(" ")
+// can be resolved.
+var constantMethods = map[string]bool{
+	"concat": true, "join": true, "toLowerCase": true, "toUpperCase": true, "trim": true,
+}
+
+// evaluationTimeLimit bounds the time spent evaluating the constants of a script
+const evaluationTimeLimit = 500 * time.Millisecond
+
+var (
+	errEvaluationHalted = errors.New("evaluation took too long")
+	errNotConstant      = errors.New("not a constant expression (only literals, +, joins and other constant top-level vars are allowed)")
+)
+
+// manifestEvaluator resolves manifest initializers that are constant expressions,
+// such as PRODUCT + " checkout", using the otto interpreter. Only the top-level
+// variables whose initializers are made of literals, string concatenation, the
+// constantMethods and references to other such variables are evaluated, so
+// the casper.* calls of the script are never run.
+type manifestEvaluator struct {
+	program  *ast.Program
+	prepared bool
+	vm       *otto.Otto
+	// constants holds the names of the top-level variables that were evaluated
+	constants map[string]bool
+	// failures holds the evaluation error of each constant variable that could not be resolved
+	failures map[string]error
+}
+
+// newManifestEvaluator creates an evaluator for the given parsed script. The
+// constants are only evaluated on the first call to value.
+func newManifestEvaluator(program *ast.Program) *manifestEvaluator {
+	return &manifestEvaluator{program: program}
+}
+
+// value returns the textual value of the variable initializer, evaluating it if needed.
+// Arrays are returned as comma-separated lists.
+func (e *manifestEvaluator) value(varExpr *ast.VariableExpression) (string, error) {
+
+	if literal, ok := literalValue(varExpr.Initializer); ok {
+		return literal, nil
+	}
+
+	e.prepare()
+	if err, failed := e.failures[varExpr.Name]; failed {
+		return "", err
+	}
+	if !e.constants[varExpr.Name] {
+		return "", errNotConstant
+	}
+
+	result, err := e.vm.Get(varExpr.Name)
+	if err != nil {
+		return "", err
+	}
+	return e.format(result)
+}
+
+// expressionValue returns the textual value of a constant expression found anywhere
+// in the script, such as a property of the MANIFEST object literal
+func (e *manifestEvaluator) expressionValue(expr ast.Expression) (string, error) {
+
+	if literal, ok := literalValue(expr); ok {
+		return literal, nil
+	}
+
+	e.prepare()
+	if !isConstantExpression(expr, e.constants) {
+		return "", errNotConstant
+	}
+
+	result, err := e.run(&ast.Program{
+		Body: []ast.Statement{&ast.ExpressionStatement{Expression: expr}},
+		File: e.program.File,
+	})
+	if err != nil {
+		return "", err
+	}
+	return e.format(result)
+}
+
+// format converts an evaluated value to text, joining arrays with commas.
+// Objects, such as the MANIFEST_SCRIPT_PARAMS declaration, are converted to JSON.
+func (e *manifestEvaluator) format(result otto.Value) (string, error) {
+
+	if result.Class() == "Object" {
+		encoded, err := e.vm.Call("JSON.stringify", nil, result)
+		if err != nil {
+			return "", err
+		}
+		return encoded.ToString()
+	}
+
+	if result.Class() == "Array" {
+		joined, err := result.Object().Call("join", ",")
+		if err != nil {
+			return "", err
+		}
+		result = joined
+	}
+	return result.ToString()
+}
+
+// prepare evaluates, in declaration order, every top-level variable whose initializer
+// is a constant expression, in a fresh interpreter
+func (e *manifestEvaluator) prepare() {
+
+	if e.prepared {
+		return
+	}
+	e.prepared = true
+	e.vm = otto.New()
+	e.failures = make(map[string]error)
+	e.constants = make(map[string]bool)
+
+	for _, declaration := range e.program.DeclarationList {
+
+		varDecl, ok := declaration.(*ast.VariableDeclaration)
+		if !ok {
+			continue
+		}
+
+		for _, varExpr := range varDecl.List {
+
+			if varExpr.Initializer == nil || !isConstantExpression(varExpr.Initializer, e.constants) {
+				continue
+			}
+
+			// Run a program made of this single declaration, reusing the parsed nodes
+			// rather than slicing the source
+			statement := &ast.Program{
+				Body: []ast.Statement{&ast.VariableStatement{
+					Var:  varDecl.Var,
+					List: []ast.Expression{varExpr},
+				}},
+				DeclarationList: []ast.Declaration{&ast.VariableDeclaration{
+					Var:  varDecl.Var,
+					List: []*ast.VariableExpression{varExpr},
+				}},
+				File: e.program.File,
+			}
+			if _, err := e.run(statement); err != nil {
+				e.failures[varExpr.Name] = err
+				continue
+			}
+			e.constants[varExpr.Name] = true
+		}
+	}
+}
+
+// run executes the program in the evaluator's interpreter, halting it if it
+// exceeds evaluationTimeLimit, and returns its completion value
+func (e *manifestEvaluator) run(program *ast.Program) (result otto.Value, err error) {
+
+	defer func() {
+		if caught := recover(); caught != nil {
+			if caught != errEvaluationHalted {
+				panic(caught)
+			}
+			err = errEvaluationHalted
+		}
+	}()
+
+	e.vm.Interrupt = make(chan func(), 1)
+	timer := time.AfterFunc(evaluationTimeLimit, func() {
+		e.vm.Interrupt <- func() { panic(errEvaluationHalted) }
+	})
+	defer timer.Stop()
+
+	return e.vm.Run(program)
+}
+
+// isConstantExpression tells whether the expression only consists of literals,
+// concatenations, calls to the constantMethods, and references to the already
+// known constants
+func isConstantExpression(expr ast.Expression, constants map[string]bool) bool {
+
+	switch node := expr.(type) {
+	case *ast.StringLiteral, *ast.NumberLiteral, *ast.BooleanLiteral, *ast.NullLiteral:
+		return true
+	case *ast.Identifier:
+		return constants[node.Name]
+	case *ast.BinaryExpression:
+		return node.Operator == token.PLUS &&
+			isConstantExpression(node.Left, constants) && isConstantExpression(node.Right, constants)
+	case *ast.ArrayLiteral:
+		for _, element := range node.Value {
+			if !isConstantExpression(element, constants) {
+				return false
+			}
+		}
+		return true
+	case *ast.ObjectLiteral:
+		for _, property := range node.Value {
+			if property.Kind != "value" || !isConstantExpression(property.Value, constants) {
+				return false
+			}
+		}
+		return true
+	case *ast.CallExpression:
+		callee, ok := node.Callee.(*ast.DotExpression)
+		if !ok || !constantMethods[callee.Identifier.Name] || !isConstantExpression(callee.Left, constants) {
+			return false
+		}
+		for _, argument := range node.ArgumentList {
+			if !isConstantExpression(argument, constants) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
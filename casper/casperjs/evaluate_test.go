@@ -0,0 +1,97 @@
+package casperjs
+
+import (
+	"testing"
+
+	"github.com/robertkrimen/otto/ast"
+	"github.com/robertkrimen/otto/parser"
+)
+
+// parseVariables parses the script, and returns its top-level variable declarations by name
+func parseVariables(t *testing.T, script string) (*ast.Program, map[string]*ast.VariableExpression) {
+
+	program, err := parser.ParseFile(nil, "", script, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	variables := make(map[string]*ast.VariableExpression)
+	for _, declaration := range program.DeclarationList {
+		if varDecl, ok := declaration.(*ast.VariableDeclaration); ok {
+			for _, varExpr := range varDecl.List {
+				variables[varExpr.Name] = varExpr
+			}
+		}
+	}
+	return program, variables
+}
+
+func TestManifestEvaluatorValue(t *testing.T) {
+
+	program, variables := parseVariables(t, `
+		var PRODUCT = "Shop";
+		var PREFIX = PRODUCT.toLowerCase() + "-";
+		var MANIFEST_SCRIPT_ID = PREFIX + "checkout";
+		var MANIFEST_SCRIPT_NAME = [PRODUCT, "checkout"].join(" ");
+		var MANIFEST_SCRIPT_TAGS = ["smoke", PRODUCT];
+		var MANIFEST_SCRIPT_TIMEOUT = 30;
+		var MANIFEST_SCRIPT_RETRIES = UNDEFINED + 1;
+		var MANIFEST_SCRIPT_PARAMS = {targetUrl: null, user: "guest"};
+		var MANIFEST_SCRIPT_DESC = casper.cli.get("desc");
+	`)
+	evaluator := newManifestEvaluator(program)
+
+	cases := []struct {
+		variable string
+		want     string
+		wantErr  bool
+	}{
+		{"MANIFEST_SCRIPT_ID", "shop-checkout", false},
+		{"MANIFEST_SCRIPT_NAME", "Shop checkout", false},
+		{"MANIFEST_SCRIPT_TAGS", "smoke,Shop", false},
+		{"MANIFEST_SCRIPT_TIMEOUT", "30", false},
+		{"MANIFEST_SCRIPT_PARAMS", `{"targetUrl":null,"user":"guest"}`, false},
+		{"MANIFEST_SCRIPT_DESC", "", true},
+		{"MANIFEST_SCRIPT_RETRIES", "", true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.variable, func(t *testing.T) {
+			got, err := evaluator.value(variables[tc.variable])
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("got error %v, want an error: %t", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestManifestEvaluatorObjectManifest(t *testing.T) {
+
+	program, variables := parseVariables(t, `
+		var PRODUCT = "Shop";
+		var MANIFEST = {id: PRODUCT.toLowerCase(), name: PRODUCT + " home", desc: document.title};
+	`)
+	evaluator := newManifestEvaluator(program)
+
+	properties, err := objectManifestProperties(variables[ObjectManifestVariable].Initializer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{"id": "shop", "name": "Shop home"}
+	for _, property := range properties {
+		got, err := evaluator.expressionValue(property.Value)
+		if property.Key == "desc" {
+			if err == nil {
+				t.Errorf("desc: got %q, want an error for a non-constant expression", got)
+			}
+			continue
+		}
+		if err != nil || got != want[property.Key] {
+			t.Errorf("%s: got %q (error %v), want %q", property.Key, got, err, want[property.Key])
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package casperjs
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// HistoryEntry is the outcome of a single test in a single run, as appended to the
+// JSON-lines history file of the test
+type HistoryEntry struct {
+	RunStartedAt time.Time
+	Id           string
+	Key          string
+	Status       TestStatus
+	Attempts     int
+	Passed       int
+	Failed       int
+	Skipped      int
+	Errors       int
+	Duration     time.Duration
+	// Failures holds the messages of the failed assertions and the runner errors of every
+	// attempt, so that the failed attempts of a flaky test are recorded as well
+	Failures []string `json:",omitempty"`
+}
+
+// historyFile returns the JSON-lines file holding the history of the test with the given
+// id. Every matrix cell of the test shares that file, their entries telling them apart by Key.
+func historyFile(historyDir string, id string) string {
+	return filepath.Join(historyDir, strings.Trim(unsafeDirCharsRegex.ReplaceAllString(id, "_"), "_")+".jsonl")
+}
+
+// newHistoryEntry summarizes the result of the test for the history
+func newHistoryEntry(record *RunRecord, t *CasperTest) *HistoryEntry {
+
+	r := t.Result
+	entry := &HistoryEntry{
+		RunStartedAt: record.StartedAt,
+		Id:           t.Id,
+		Key:          t.Key(),
+		Status:       r.Status,
+		Attempts:     len(t.Attempts),
+		Passed:       r.Count(AssertionPassed),
+		Failed:       r.Count(AssertionFailed),
+		Skipped:      r.Count(AssertionSkipped),
+		Errors:       len(r.Errors),
+		Duration:     r.Duration,
+	}
+
+	// Tests skipped because of their prerequisites have a result but no attempts
+	attempts := t.Attempts
+	if len(attempts) == 0 {
+		attempts = []*TestResult{r}
+	}
+
+	for _, attempt := range attempts {
+		prefix := ""
+		if len(attempts) > 1 {
+			prefix = fmt.Sprintf("attempt %d: ", attempt.Attempt)
+		}
+		for _, a := range attempt.Assertions {
+			if a.Status == AssertionFailed {
+				entry.Failures = append(entry.Failures, prefix+a.Message)
+			}
+		}
+		for _, err := range attempt.Errors {
+			entry.Failures = append(entry.Failures, prefix+err)
+		}
+	}
+	return entry
+}
+
+// AppendHistory appends the results of the run to the history files under historyDir.
+// Tests that have not produced a result, or were cancelled, are left out.
+func AppendHistory(historyDir string, record *RunRecord) error {
+
+	if err := os.MkdirAll(historyDir, 0755); err != nil {
+		return err
+	}
+
+	for _, t := range record.Tests {
+		if t.Result == nil || t.Result.Status == StatusCancelled {
+			continue
+		}
+
+		line, err := json.Marshal(newHistoryEntry(record, t))
+		if err != nil {
+			return err
+		}
+
+		file, err := os.OpenFile(historyFile(historyDir, t.Id), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		_, err = file.Write(append(line, '\n'))
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadHistory reads the history of the test with the given id, oldest run first.
+// Lines that cannot be decoded, such as one cut short by an interrupted run, are skipped.
+func LoadHistory(historyDir string, id string) ([]*HistoryEntry, error) {
+
+	file, err := os.Open(historyFile(historyDir, id))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	entries := make([]*HistoryEntry, 0)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		entry := &HistoryEntry{}
+		if err := json.Unmarshal(scanner.Bytes(), entry); err != nil {
+			continue
+		}
+		if entry.Id == id {
+			entries = append(entries, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return entries, err
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].RunStartedAt.Before(entries[j].RunStartedAt)
+	})
+	return entries, nil
+}
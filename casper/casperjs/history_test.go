@@ -0,0 +1,86 @@
+package casperjs
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNewHistoryEntry(t *testing.T) {
+
+	record := &RunRecord{StartedAt: time.Date(2026, 1, 2, 15, 0, 0, 0, time.UTC)}
+	result := parseOutput(failedOutput)
+	result.Errors = append(result.Errors, "exit status 1")
+	c := &CasperTest{Id: "home", Cell: &MatrixCell{Key: "env=prod"}, Result: result, Attempts: []*TestResult{result}}
+
+	entry := newHistoryEntry(record, c)
+	if entry.Id != "home" || entry.Key != "home[env=prod]" || !entry.RunStartedAt.Equal(record.StartedAt) {
+		t.Errorf("got entry for %s, %s at %s", entry.Id, entry.Key, entry.RunStartedAt)
+	}
+	if entry.Attempts != 1 || entry.Passed != 1 || entry.Failed != 1 || entry.Errors != 1 {
+		t.Errorf("got %d attempt(s), %d passed, %d failed, %d errors", entry.Attempts, entry.Passed, entry.Failed, entry.Errors)
+	}
+	if want := []string{"link not found", "exit status 1"}; !reflect.DeepEqual(entry.Failures, want) {
+		t.Errorf("got failures %q, want %q", entry.Failures, want)
+	}
+}
+
+func TestNewHistoryEntryFlaky(t *testing.T) {
+
+	failed, passed := parseOutput(failedOutput), parseOutput(passedOutput)
+	failed.Attempt, passed.Attempt = 1, 2
+	flaky := *passed
+	flaky.Status = StatusFlaky
+	c := &CasperTest{Id: "home", Result: &flaky, Attempts: []*TestResult{failed, passed}}
+
+	entry := newHistoryEntry(&RunRecord{}, c)
+	if entry.Status != StatusFlaky || entry.Attempts != 2 {
+		t.Errorf("got a %s entry with %d attempts, want a flaky one with 2", entry.Status, entry.Attempts)
+	}
+	if want := []string{"attempt 1: link not found"}; !reflect.DeepEqual(entry.Failures, want) {
+		t.Errorf("got failures %q, want the failure of the first attempt %q", entry.Failures, want)
+	}
+}
+
+func TestAppendAndLoadHistory(t *testing.T) {
+
+	historyDir := t.TempDir()
+	earlier := time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)
+	later := earlier.Add(24 * time.Hour)
+
+	tests := []*CasperTest{
+		{Id: "home", Result: parseOutput(passedOutput)},
+		{Id: "checkout", Result: parseOutput(failedOutput)},
+		{Id: "search"},
+		{Id: "cart", Result: &TestResult{Status: StatusCancelled}},
+	}
+	// Appended out of order, to check that the entries come back oldest first
+	for _, startedAt := range []time.Time{later, earlier} {
+		if err := AppendHistory(historyDir, &RunRecord{StartedAt: startedAt, Tests: tests}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A line cut short by an interrupted run is skipped
+	file, err := os.OpenFile(historyFile(historyDir, "home"), os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(`{"Id": "home", "Sta`)
+	file.Close()
+
+	entries, err := LoadHistory(historyDir, "home")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || !entries[0].RunStartedAt.Equal(earlier) || entries[1].Status != StatusPassed {
+		t.Fatalf("got %d entries, want the two runs of home, oldest first", len(entries))
+	}
+
+	for _, id := range []string{"search", "cart"} {
+		if _, err := LoadHistory(historyDir, id); !os.IsNotExist(err) {
+			t.Errorf("%s: got error %v, want no history for a test without result or cancelled", id, err)
+		}
+	}
+}
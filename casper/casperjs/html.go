@@ -0,0 +1,233 @@
+package casperjs
+
+import (
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// maxEmbeddedImageSize is the size above which screenshots are linked from the HTML
+// report rather than embedded in it, to keep the report reasonably small
+const maxEmbeddedImageSize = 2 << 20
+
+// htmlReport is the data the HTML report template is rendered from
+type htmlReport struct {
+	Record      *RunRecord
+	GeneratedAt time.Time
+	Tests       []*htmlTest
+	Statuses    map[TestStatus]int
+	Passed      int
+	Failed      int
+	Skipped     int
+	Errors      int
+}
+
+// htmlTest is a single test of the HTML report, with its attempts and screenshots
+type htmlTest struct {
+	*CasperTest
+	Anchor      string
+	Output      string
+	Screenshots []*htmlImage
+}
+
+// htmlImage is a screenshot of the HTML report. Source is either a data URI holding
+// the whole image, or a path relative to the report.
+type htmlImage struct {
+	Name   string
+	Kind   string
+	Source template.URL
+}
+
+// WriteHTMLReport writes a single-file HTML report for the run to the file at path,
+// embedding the screenshots that are small enough
+func WriteHTMLReport(path string, record *RunRecord) error {
+
+	report := &htmlReport{
+		Record:      record,
+		GeneratedAt: time.Now(),
+		Statuses:    make(map[TestStatus]int),
+	}
+
+	for _, t := range record.Tests {
+		test := &htmlTest{CasperTest: t, Anchor: "test-" + t.dirName()}
+		report.Tests = append(report.Tests, test)
+
+		if t.Result == nil {
+			report.Statuses[StatusUnknown]++
+			continue
+		}
+
+		r := t.Result
+		report.Statuses[r.Status]++
+		report.Passed += r.Count(AssertionPassed)
+		report.Failed += r.Count(AssertionFailed)
+		report.Skipped += r.Count(AssertionSkipped)
+		report.Errors += len(r.Errors)
+		test.Output = attemptsOutput(t)
+
+		for _, artifact := range r.Artifacts {
+			if artifact.Kind == "screenshot" || artifact.Kind == "diff" {
+				test.Screenshots = append(test.Screenshots, reportImage(path, artifact))
+			}
+		}
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return htmlReportTemplate.Execute(file, report)
+}
+
+// reportImage embeds the artifact as a data URI, or links it relative to the report
+// at reportPath when it is too large or cannot be read
+func reportImage(reportPath string, artifact *Artifact) *htmlImage {
+
+	image := &htmlImage{Name: filepath.Base(artifact.Path), Kind: artifact.Kind}
+
+	mimeType := mime.TypeByExtension(filepath.Ext(artifact.Path))
+	if strings.HasPrefix(mimeType, "image/") && artifact.Size <= maxEmbeddedImageSize {
+		if contents, err := ioutil.ReadFile(artifact.Path); err == nil {
+			image.Source = template.URL("data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(contents))
+			return image
+		}
+	}
+
+	link := artifact.Path
+	if reportDir, err := filepath.Abs(filepath.Dir(reportPath)); err == nil {
+		if absPath, err := filepath.Abs(artifact.Path); err == nil {
+			if relPath, err := filepath.Rel(reportDir, absPath); err == nil {
+				link = relPath
+			}
+		}
+	}
+	image.Source = template.URL(filepath.ToSlash(link))
+	return image
+}
+
+// htmlReportTemplate renders the HTML report, with inline styles so that the
+// file can be shared on its own
+var htmlReportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"formatTime": func(t time.Time) string {
+		if t.IsZero() {
+			return "-"
+		}
+		return t.Format("2006-01-02 15:04:05")
+	},
+	"formatDuration": func(d time.Duration) string {
+		return d.Round(time.Millisecond).String()
+	},
+	"lower": func(s interface{}) string {
+		return strings.ToLower(fmt.Sprint(s))
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Casper test report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; vertical-align: top; }
+th { background: #f0f0f0; }
+.passed, .pass { color: #18794e; }
+.failed, .fail, .error, .timedout { color: #c62828; }
+.flaky, .skip, .skipped, .cancelled, .unknown { color: #b26a00; }
+.test { border-top: 2px solid #ddd; margin-top: 2em; padding-top: 1em; }
+.context { color: #666; font-size: 0.9em; }
+.details { color: #666; font-size: 0.9em; white-space: pre-wrap; }
+figure { display: inline-block; margin: 0 1em 1em 0; }
+figure img { max-width: 400px; border: 1px solid #ccc; }
+pre { background: #f7f7f7; padding: 1em; overflow-x: auto; }
+</style>
+</head>
+<body>
+<h1>Casper test report</h1>
+<p>Run started {{formatTime .Record.StartedAt}}, finished {{formatTime .Record.FinishedAt}}. Report generated {{formatTime .GeneratedAt}}.</p>
+
+<h2>Summary</h2>
+<p>
+{{len .Tests}} test(s):
+{{range $status, $count := .Statuses}}<span class="{{lower $status}}">{{$count}} {{$status}}</span> {{end}}
+&mdash; {{.Passed}} assertion(s) passed, {{.Failed}} failed, {{.Skipped}} skipped, {{.Errors}} runner error(s).
+</p>
+<table>
+<tr><th>ID</th><th>Name</th><th>Status</th><th>Attempts</th><th>Passed</th><th>Failed</th><th>Skipped</th><th>Errors</th><th>Duration</th></tr>
+{{range .Tests}}{{if .Result}}
+<tr><td><a href="#{{.Anchor}}">{{.Key}}</a></td><td>{{.Name}}</td><td class="{{lower .Result.Status}}">{{.Result.Status}}</td><td>{{len .Attempts}}</td>
+<td>{{.Result.Count "PASS"}}</td><td>{{.Result.Count "FAIL"}}</td><td>{{.Result.Count "SKIP"}}</td><td>{{len .Result.Errors}}</td><td>{{formatDuration .Result.Duration}}</td></tr>
+{{else}}
+<tr><td><a href="#{{.Anchor}}">{{.Key}}</a></td><td>{{.Name}}</td><td class="unknown">unknown</td><td>0</td><td>-</td><td>-</td><td>-</td><td>-</td><td>-</td></tr>
+{{end}}{{end}}
+</table>
+
+{{range .Tests}}
+<div class="test" id="{{.Anchor}}">
+<h2>{{.Name}}{{if .Cell}} [{{.Cell.Key}}]{{end}}</h2>
+<p>{{.Description}}</p>
+<p>Script: <code>{{.FilePath}}</code>{{if .Tags}} &mdash; tags: {{range $i, $tag := .Tags}}{{if $i}}, {{end}}{{$tag}}{{end}}{{end}}</p>
+{{with .Result}}
+<p>Status: <strong class="{{lower .Status}}">{{.Status}}</strong>, started {{formatTime .StartedAt}}, took {{formatDuration .Duration}}{{if .ArtifactDir}}, artifacts in <code>{{.ArtifactDir}}</code>{{end}}.</p>
+
+{{if .SkipReason}}<p class="skipped">Not run: {{.SkipReason}}</p>{{end}}
+
+{{if .Errors}}
+<h3>Runner errors</h3>
+<ul>{{range .Errors}}<li class="error">{{.}}</li>{{end}}</ul>
+{{end}}
+
+{{if .Assertions}}
+<h3>Assertions</h3>
+<table>
+<tr><th>Status</th><th>Suite</th><th>Assertion</th><th>Duration</th></tr>
+{{range .Assertions}}
+<tr><td class="{{lower .Status}}">{{.Status}}</td><td>{{.Suite}}</td>
+<td>{{.Message}}{{if .Context}}<div class="context">{{.Context}}</div>{{end}}{{if .Details}}<div class="details">{{range $key, $value := .Details}}{{$key}}: {{$value}}
+{{end}}</div>{{end}}</td>
+<td>{{formatDuration .Duration}}</td></tr>
+{{end}}
+</table>
+{{end}}
+{{end}}
+
+{{if gt (len .Attempts) 1}}
+<h3>Attempts</h3>
+<table>
+<tr><th>Attempt</th><th>Status</th><th>Started</th><th>Duration</th><th>Failed assertions</th></tr>
+{{range .Attempts}}<tr><td>{{.Attempt}}</td><td class="{{lower .Status}}">{{.Status}}</td><td>{{formatTime .StartedAt}}</td><td>{{formatDuration .Duration}}</td><td>{{.Count "FAIL"}}</td></tr>
+{{end}}
+</table>
+{{end}}
+
+{{if .Screenshots}}
+<h3>Screenshots</h3>
+{{range .Screenshots}}<figure><a href="{{.Source}}"><img src="{{.Source}}" alt="{{.Name}}"></a><figcaption>{{.Name}}{{if eq .Kind "diff"}} (differences in red){{end}}</figcaption></figure>
+{{end}}
+{{end}}
+
+{{if .Output}}
+<details>
+<summary>CasperJS output</summary>
+<pre>{{.Output}}</pre>
+</details>
+{{end}}
+{{with .Result}}{{if .Stderr}}
+<details>
+<summary>CasperJS stderr</summary>
+<pre class="error">{{range .Stderr}}{{.}}
+{{end}}</pre>
+</details>
+{{end}}{{end}}
+</div>
+{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,220 @@
+package casperjs
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// junitTestSuites is the root element of a JUnit XML report
+type junitTestSuites struct {
+	XMLName xml.Name          `xml:"testsuites"`
+	Suites  []*junitTestSuite `xml:"testsuite"`
+}
+
+// junitTestSuite maps a single CasperTest, named after its MANIFEST_SCRIPT_NAME
+type junitTestSuite struct {
+	Name      string           `xml:"name,attr"`
+	Tests     int              `xml:"tests,attr"`
+	Failures  int              `xml:"failures,attr"`
+	Errors    int              `xml:"errors,attr"`
+	Skipped   int              `xml:"skipped,attr"`
+	Time      string           `xml:"time,attr"`
+	Timestamp string           `xml:"timestamp,attr,omitempty"`
+	TestCases []*junitTestCase `xml:"testcase"`
+	SystemOut *junitText       `xml:"system-out,omitempty"`
+	SystemErr *junitText       `xml:"system-err,omitempty"`
+}
+
+// junitTestCase maps a single CasperJS assertion
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Error     *junitError   `xml:"error,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+}
+
+// junitSkipped marks a skipped assertion, or a test skipped as a whole
+type junitSkipped struct {
+	Message string `xml:"message,attr,omitempty"`
+}
+
+// junitFailure describes a failed assertion
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr,omitempty"`
+	Body    string `xml:",cdata"`
+}
+
+// junitError describes a test that could not run to completion
+type junitError struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr,omitempty"`
+}
+
+// junitText holds captured output, kept as CDATA so that line breaks stay readable
+type junitText struct {
+	Text string `xml:",cdata"`
+}
+
+// newJUnitText wraps the text, or returns nil if there is none
+func newJUnitText(text string) *junitText {
+
+	if text == "" {
+		return nil
+	}
+	return &junitText{Text: text}
+}
+
+// WriteJUnitReport writes a JUnit XML report for the given tests to the file at path.
+// Tests that have not produced a result yet, or were cancelled, are left out.
+func WriteJUnitReport(path string, tests []*CasperTest) error {
+
+	report := &junitTestSuites{}
+	for _, t := range tests {
+		if t.Result != nil && t.Result.Status != StatusCancelled {
+			report.Suites = append(report.Suites, newJUnitTestSuite(t))
+		}
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err := file.WriteString(xml.Header); err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(file)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return err
+	}
+
+	_, err = file.WriteString("\n")
+	return err
+}
+
+// newJUnitTestSuite converts the result of a CasperTest into a JUnit test suite
+func newJUnitTestSuite(t *CasperTest) *junitTestSuite {
+
+	r := t.Result
+	suite := &junitTestSuite{
+		Name:      t.Name,
+		Tests:     len(r.Assertions),
+		Failures:  r.Count(AssertionFailed),
+		Skipped:   r.Count(AssertionSkipped),
+		Time:      junitSeconds(r.Duration.Seconds()),
+		SystemOut: newJUnitText(attemptsOutput(t)),
+		SystemErr: newJUnitText(strings.Join(append(append([]string{}, r.Errors...), r.Stderr...), "\n")),
+	}
+	if t.Cell != nil {
+		suite.Name = fmt.Sprintf("%s [%s]", t.Name, t.Cell.Key)
+	}
+	if !r.StartedAt.IsZero() {
+		suite.Timestamp = r.StartedAt.Format("2006-01-02T15:04:05")
+	}
+
+	for _, a := range r.Assertions {
+		testCase := &junitTestCase{
+			Name:      a.Message,
+			ClassName: t.Key(),
+			Time:      junitSeconds(a.Duration.Seconds()),
+		}
+
+		switch a.Status {
+		case AssertionFailed:
+			testCase.Failure = &junitFailure{
+				Message: a.Message,
+				Type:    a.Details["type"],
+				Body:    formatDetails(a.Details),
+			}
+		case AssertionSkipped:
+			testCase.Skipped = &junitSkipped{}
+		}
+
+		suite.TestCases = append(suite.TestCases, testCase)
+	}
+
+	// A test skipped because of its prerequisites has no assertions, so it gets a single skipped test case
+	if r.Status == StatusSkipped {
+		suite.Tests++
+		suite.Skipped++
+		suite.TestCases = append(suite.TestCases, &junitTestCase{
+			Name:      "skipped",
+			ClassName: t.Key(),
+			Time:      junitSeconds(0),
+			Skipped:   &junitSkipped{Message: r.SkipReason},
+		})
+	}
+
+	// A hung script is reported as an extra failed test case, since its assertions are incomplete
+	if r.Status == StatusTimedOut {
+		suite.Tests++
+		suite.Failures++
+		suite.TestCases = append(suite.TestCases, &junitTestCase{
+			Name:      "timeout",
+			ClassName: t.Key(),
+			Time:      junitSeconds(r.Duration.Seconds()),
+			Failure:   &junitFailure{Message: "test timed out", Type: "timeout"},
+		})
+	}
+
+	// A test that errored may have no assertions at all, e.g. when casperjs is missing or
+	// crashed, so it also gets an extra test case, lest CI counts it as an empty suite
+	if r.Status == StatusError {
+		suite.Tests++
+		suite.Errors++
+		suite.TestCases = append(suite.TestCases, &junitTestCase{
+			Name:      "error",
+			ClassName: t.Key(),
+			Time:      junitSeconds(r.Duration.Seconds()),
+			Error:     &junitError{Message: strings.Join(r.Errors, "; "), Type: "error"},
+		})
+	}
+
+	return suite
+}
+
+// attemptsOutput joins the captured output of the test. When the test was retried,
+// the output of every attempt is included, each under its own header line.
+func attemptsOutput(t *CasperTest) string {
+
+	if len(t.Attempts) <= 1 {
+		return strings.Join(t.Result.Output, "\n")
+	}
+
+	sections := make([]string, 0, len(t.Attempts))
+	for _, attempt := range t.Attempts {
+		header := fmt.Sprintf("----- Attempt %d (%s) -----", attempt.Attempt, attempt.Status)
+		sections = append(sections, header+"\n"+strings.Join(attempt.Output, "\n"))
+	}
+	return strings.Join(sections, "\n")
+}
+
+// formatDetails renders the "#    key: value" details of an assertion, sorted by key
+func formatDetails(details map[string]string) string {
+
+	keys := make([]string, 0, len(details))
+	for k := range details {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	lines := make([]string, 0, len(keys))
+	for _, k := range keys {
+		lines = append(lines, fmt.Sprintf("%s: %s", k, details[k]))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// junitSeconds formats a number of seconds the way JUnit consumers expect
+func junitSeconds(seconds float64) string {
+	return fmt.Sprintf("%.3f", seconds)
+}
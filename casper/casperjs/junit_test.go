@@ -0,0 +1,99 @@
+package casperjs
+
+import (
+	"encoding/xml"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNewJUnitTestSuite(t *testing.T) {
+
+	c := &CasperTest{Id: "home", Name: "Home page"}
+	c.Result = parseOutput("# Home page\n" +
+		"PASS title matches\n" +
+		"FAIL link not found\n" +
+		"#    type: assert\n" +
+		"SKIP not implemented yet\n" +
+		"FAIL 3 tests executed in 1.2s, 1 passed, 1 failed, 0 dubious, 1 skipped.")
+
+	suite := newJUnitTestSuite(c)
+	if suite.Name != "Home page" || suite.Tests != 3 || suite.Failures != 1 || suite.Skipped != 1 || suite.Errors != 0 {
+		t.Errorf("got suite %s with %d tests, %d failures, %d skipped and %d errors, want Home page with 3, 1, 1 and 0",
+			suite.Name, suite.Tests, suite.Failures, suite.Skipped, suite.Errors)
+	}
+	if len(suite.TestCases) != 3 {
+		t.Fatalf("got %d test cases, want 3", len(suite.TestCases))
+	}
+
+	failed := suite.TestCases[1]
+	if failed.ClassName != "home" || failed.Failure == nil {
+		t.Fatalf("got test case %+v, want a failure of class home", failed)
+	}
+	if failed.Failure.Message != "link not found" || failed.Failure.Type != "assert" || failed.Failure.Body != "type: assert" {
+		t.Errorf("got failure %+v", failed.Failure)
+	}
+	if suite.TestCases[0].Failure != nil || suite.TestCases[2].Skipped == nil {
+		t.Error("got the passed or skipped assertion reported wrongly")
+	}
+}
+
+func TestWriteJUnitReport(t *testing.T) {
+
+	passed := &CasperTest{Id: "a", Name: "a"}
+	passed.Result = parseOutput(passedOutput)
+	notRun := &CasperTest{Id: "b", Name: "b"}
+	cancelled := &CasperTest{Id: "c", Name: "c", Result: &TestResult{Status: StatusCancelled}}
+
+	path := filepath.Join(t.TempDir(), "junit.xml")
+	if err := WriteJUnitReport(path, []*CasperTest{passed, notRun, cancelled}); err != nil {
+		t.Fatal(err)
+	}
+
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := &junitTestSuites{}
+	if err := xml.Unmarshal(contents, report); err != nil {
+		t.Fatalf("invalid report: %s", err)
+	}
+	if len(report.Suites) != 1 || report.Suites[0].Name != "a" || len(report.Suites[0].TestCases) != 1 {
+		t.Errorf("got %d suites, want only the one of the test that ran", len(report.Suites))
+	}
+}
+
+func TestNewJUnitTestSuiteTimedOut(t *testing.T) {
+
+	parser := newOutputParser(time.Now())
+	parser.parseLine("PASS title matches", time.Now())
+	parser.markTimedOut(2 * time.Second)
+	c := &CasperTest{Id: "home", Name: "Home page", Result: parser.finish(time.Now())}
+
+	suite := newJUnitTestSuite(c)
+	if suite.Tests != 2 || suite.Failures != 1 {
+		t.Errorf("got %d tests and %d failures, want the timeout as an extra failed test case", suite.Tests, suite.Failures)
+	}
+	last := suite.TestCases[len(suite.TestCases)-1]
+	if last.Name != "timeout" || last.Failure == nil || last.Failure.Type != "timeout" {
+		t.Errorf("got last test case %+v, want the timeout", last)
+	}
+}
+
+func TestNewJUnitTestSuiteError(t *testing.T) {
+
+	parser := newOutputParser(time.Now())
+	parser.addError(errors.New(`exec: "casperjs": executable file not found in $PATH`))
+	c := &CasperTest{Id: "home", Name: "Home page", Result: parser.finish(time.Now())}
+
+	suite := newJUnitTestSuite(c)
+	if suite.Tests != 1 || suite.Errors != 1 || len(suite.TestCases) != 1 {
+		t.Fatalf("got %d tests and %d errors, want the error as a test case of its own", suite.Tests, suite.Errors)
+	}
+	errored := suite.TestCases[0]
+	if errored.Error == nil || errored.Error.Message != `exec: "casperjs": executable file not found in $PATH` {
+		t.Errorf("got test case %+v, want an error element", errored)
+	}
+}
@@ -0,0 +1,67 @@
+package casperjs
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/robertkrimen/otto/ast"
+)
+
+// ObjectManifestVariable names the single object-literal manifest, accepted as an
+// alternative to the separate MANIFEST_SCRIPT_* variables:
+//
+//	var MANIFEST = { id: "...", name: "...", desc: "...", tags: [...], timeout: 60, retries: 1, params: {...}, depends: [...] };
+const ObjectManifestVariable = "MANIFEST"
+
+// objectManifestKeys maps the keys of the MANIFEST object to the manifest variables they stand for
+var objectManifestKeys = map[string]string{
+	"id":          "MANIFEST_SCRIPT_ID",
+	"name":        "MANIFEST_SCRIPT_NAME",
+	"desc":        "MANIFEST_SCRIPT_DESC",
+	"description": "MANIFEST_SCRIPT_DESC",
+	"tags":        "MANIFEST_SCRIPT_TAGS",
+	"timeout":     "MANIFEST_SCRIPT_TIMEOUT",
+	"retries":     "MANIFEST_SCRIPT_RETRIES",
+	"params":      "MANIFEST_SCRIPT_PARAMS",
+	"depends":     "MANIFEST_SCRIPT_DEPENDS",
+}
+
+// objectManifestRegex spots the declaration of the MANIFEST object while scanning a script.
+// It does not match the MANIFEST_SCRIPT_* variables, since "_" is a word character.
+var objectManifestRegex = regexp.MustCompile(`\bMANIFEST\s*=`)
+
+// manifestProperty is a single property of the MANIFEST object
+type manifestProperty struct {
+	Key         string
+	ManifestVar string
+	Value       ast.Expression
+}
+
+// objectManifestProperties returns the properties of the MANIFEST object initializer,
+// in declaration order. An error is returned if the initializer is not an object
+// literal, or if it holds keys that do not map to any manifest variable.
+func objectManifestProperties(initializer ast.Expression) ([]manifestProperty, error) {
+
+	objectLiteral, ok := initializer.(*ast.ObjectLiteral)
+	if !ok {
+		return nil, fmt.Errorf("%s must be an object literal", ObjectManifestVariable)
+	}
+
+	properties := make([]manifestProperty, 0, len(objectLiteral.Value))
+	unknownKeys := make([]string, 0)
+	for _, property := range objectLiteral.Value {
+		manifestVar, known := objectManifestKeys[property.Key]
+		if !known || property.Kind != "value" {
+			unknownKeys = append(unknownKeys, property.Key)
+			continue
+		}
+		properties = append(properties, manifestProperty{property.Key, manifestVar, property.Value})
+	}
+
+	if len(unknownKeys) > 0 {
+		sort.Strings(unknownKeys)
+		return properties, fmt.Errorf("%s holds unknown keys: %v", ObjectManifestVariable, unknownKeys)
+	}
+	return properties, nil
+}
@@ -0,0 +1,162 @@
+package casperjs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// Matrix describes the combinations of environments, user agents, etc. that every
+// Casper test is run against. Each axis contributes one value to every cell.
+type Matrix struct {
+	Axes []*MatrixAxis
+}
+
+// MatrixAxis is a single dimension of the matrix, e.g. "env" with dev, staging and prod
+type MatrixAxis struct {
+	Name   string
+	Values []*MatrixValue
+}
+
+// MatrixValue is one of the values of an axis. Besides being passed to the scripts
+// as the <axis>=<label> parameter, it may carry parameters of its own, such as the
+// targetUrl of an environment.
+type MatrixValue struct {
+	Label  string
+	Params map[string]string
+}
+
+// MatrixCell is one combination of axis values
+type MatrixCell struct {
+	// Key identifies the cell, e.g. "env=staging,userAgent=mobile"
+	Key string
+	// Values maps each axis name to the label of its value in this cell
+	Values map[string]string
+	// Params are the runtime parameters the cell adds to, or overrides in, RunOptions.Params
+	Params map[string]string
+}
+
+// Empty tells whether the matrix has no axes, in which case tests run once
+func (m *Matrix) Empty() bool {
+	return m == nil || len(m.Axes) == 0
+}
+
+// Cells returns every combination of the axis values, varying the last axis fastest
+func (m *Matrix) Cells() []*MatrixCell {
+
+	if m.Empty() {
+		return nil
+	}
+
+	cells := []*MatrixCell{{Values: map[string]string{}, Params: map[string]string{}}}
+	for _, axis := range m.Axes {
+		expanded := make([]*MatrixCell, 0, len(cells)*len(axis.Values))
+		for _, cell := range cells {
+			for _, value := range axis.Values {
+				next := &MatrixCell{Values: map[string]string{}, Params: map[string]string{}}
+				for k, v := range cell.Values {
+					next.Values[k] = v
+				}
+				for k, v := range cell.Params {
+					next.Params[k] = v
+				}
+				next.Values[axis.Name] = value.Label
+				next.Params[axis.Name] = value.Label
+				for k, v := range value.Params {
+					next.Params[k] = v
+				}
+				expanded = append(expanded, next)
+			}
+		}
+		cells = expanded
+	}
+
+	for _, cell := range cells {
+		pairs := make([]string, 0, len(m.Axes))
+		for _, axis := range m.Axes {
+			pairs = append(pairs, axis.Name+"="+cell.Values[axis.Name])
+		}
+		cell.Key = strings.Join(pairs, ",")
+	}
+	return cells
+}
+
+// ExpandMatrix returns one copy of every test per matrix cell, or the tests
+// themselves when the matrix is empty. Running the returned tests runs every
+// test once per cell, and keeps the results of every cell apart.
+func ExpandMatrix(tests []*CasperTest, matrix *Matrix) []*CasperTest {
+
+	if matrix.Empty() {
+		return tests
+	}
+
+	cells := matrix.Cells()
+	expanded := make([]*CasperTest, 0, len(tests)*len(cells))
+	for _, t := range tests {
+		for _, cell := range cells {
+			clone := *t
+			clone.Cell = cell
+			clone.Result = nil
+			clone.Attempts = nil
+			expanded = append(expanded, &clone)
+		}
+	}
+	return expanded
+}
+
+// LoadMatrixFile reads a matrix definition from a JSON file. Each key of the top-level
+// object is an axis. An axis holds either a list of values, or an object mapping each
+// value to the runtime parameters it sets:
+//
+//	{
+//	  "env": {"staging": {"targetUrl": "https://staging.example.com"}, "prod": {"targetUrl": "https://example.com"}},
+//	  "userAgent": ["Mozilla/5.0 (X11; Linux x86_64)", "Mozilla/5.0 (iPhone)"]
+//	}
+//
+// Axes and object values are sorted by name, since JSON objects carry no order.
+func LoadMatrixFile(path string) (*Matrix, error) {
+
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	definition := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(contents, &definition); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+
+	names := make([]string, 0, len(definition))
+	for name := range definition {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	matrix := &Matrix{}
+	for _, name := range names {
+		axis := &MatrixAxis{Name: name}
+
+		var labels []string
+		var valueParams map[string]map[string]string
+		if err := json.Unmarshal(definition[name], &labels); err == nil {
+			for _, label := range labels {
+				axis.Values = append(axis.Values, &MatrixValue{Label: label})
+			}
+		} else if err := json.Unmarshal(definition[name], &valueParams); err == nil {
+			for label := range valueParams {
+				axis.Values = append(axis.Values, &MatrixValue{Label: label, Params: valueParams[label]})
+			}
+			sort.Slice(axis.Values, func(i, j int) bool { return axis.Values[i].Label < axis.Values[j].Label })
+		} else {
+			return nil, fmt.Errorf("%s: axis %q must be a list of strings, or an object of parameter objects", path, name)
+		}
+
+		if len(axis.Values) == 0 {
+			return nil, fmt.Errorf("%s: axis %q has no values", path, name)
+		}
+		matrix.Axes = append(matrix.Axes, axis)
+	}
+	return matrix, nil
+}
@@ -0,0 +1,89 @@
+package casperjs
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestExpandMatrix(t *testing.T) {
+
+	matrix := &Matrix{Axes: []*MatrixAxis{
+		{Name: "env", Values: []*MatrixValue{
+			{Label: "staging", Params: map[string]string{"targetUrl": "https://staging.example.com"}},
+			{Label: "prod", Params: map[string]string{"targetUrl": "https://example.com"}},
+		}},
+		{Name: "ua", Values: []*MatrixValue{{Label: "desktop"}, {Label: "mobile"}}},
+	}}
+
+	original := &CasperTest{Id: "home", Name: "Home", Result: &TestResult{Status: StatusPassed}}
+	expanded := ExpandMatrix([]*CasperTest{original}, matrix)
+
+	wantKeys := []string{
+		"home[env=staging,ua=desktop]",
+		"home[env=staging,ua=mobile]",
+		"home[env=prod,ua=desktop]",
+		"home[env=prod,ua=mobile]",
+	}
+	gotKeys := make([]string, 0, len(expanded))
+	for _, c := range expanded {
+		gotKeys = append(gotKeys, c.Key())
+		if c.Result != nil {
+			t.Errorf("%s: the result of the original test was copied", c.Key())
+		}
+	}
+	if !reflect.DeepEqual(gotKeys, wantKeys) {
+		t.Errorf("got keys %q, want %q", gotKeys, wantKeys)
+	}
+
+	wantParams := map[string]string{"env": "prod", "ua": "mobile", "targetUrl": "https://example.com"}
+	if got := expanded[3].Cell.Params; !reflect.DeepEqual(got, wantParams) {
+		t.Errorf("got cell params %v, want %v", got, wantParams)
+	}
+	if original.Cell != nil {
+		t.Error("the original test was modified")
+	}
+}
+
+func TestExpandMatrixEmpty(t *testing.T) {
+
+	tests := []*CasperTest{{Id: "a"}, {Id: "b"}}
+	for _, matrix := range []*Matrix{nil, {}} {
+		if got := ExpandMatrix(tests, matrix); !reflect.DeepEqual(got, tests) {
+			t.Errorf("got %d tests for an empty matrix, want the tests unchanged", len(got))
+		}
+	}
+}
+
+func TestLoadMatrixFile(t *testing.T) {
+
+	dir := writeFiles(t, map[string]string{
+		"matrix.json": `{"ua": ["desktop", "mobile"], "env": {"staging": {"targetUrl": "https://staging.example.com"}, "dev": {}}}`,
+		"empty.json":  `{"env": []}`,
+		"broken.json": `{"env": "staging"}`,
+	})
+
+	matrix, err := LoadMatrixFile(filepath.Join(dir, "matrix.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotLabels := make([]string, 0)
+	for _, axis := range matrix.Axes {
+		for _, value := range axis.Values {
+			gotLabels = append(gotLabels, axis.Name+"="+value.Label)
+		}
+	}
+	wantLabels := []string{"env=dev", "env=staging", "ua=desktop", "ua=mobile"}
+	if !reflect.DeepEqual(gotLabels, wantLabels) {
+		t.Errorf("got axis values %q, want %q", gotLabels, wantLabels)
+	}
+	if got := matrix.Axes[0].Values[1].Params["targetUrl"]; got != "https://staging.example.com" {
+		t.Errorf("got targetUrl %q for staging", got)
+	}
+
+	for _, name := range []string{"empty.json", "broken.json", "missing.json"} {
+		if _, err := LoadMatrixFile(filepath.Join(dir, name)); err == nil {
+			t.Errorf("%s: got no error", name)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package casperjs
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// The streams a CasperJS output line may come from. The runner stream holds the
+// events recorded by the runner itself, such as a timeout, so that they can be replayed.
+const (
+	streamStdout = "stdout"
+	streamStderr = "stderr"
+	streamRunner = "runner"
+)
+
+// timeoutMarker starts the runner line recorded when a test is stopped for exceeding its time limit
+const timeoutMarker = "timed out after "
+
+// outputLogName is the file every attempt's output gets written to, in its artifacts directory
+const outputLogName = "casperjs.log"
+
+// outputLogTimeFormat stamps the lines of the output log files
+const outputLogTimeFormat = "2006-01-02T15:04:05.000Z07:00"
+
+// outputLogLineRegex matches the "<time> <stream> | <text>" lines of the output log files
+var outputLogLineRegex = regexp.MustCompile(`^\S+ (stdout|stderr|runner) \| (.*)$`)
+
+// OutputLine is a single line written by CasperJS, along with the stream it came from
+// and the moment it was received
+type OutputLine struct {
+	At     time.Time
+	Stream string
+	Text   string
+}
+
+// readLines reads r to the end, calling handle for every line without its line ending.
+// Unlike bufio.Scanner, it accepts lines of any length, and a last line without a newline.
+func readLines(r io.Reader, handle func(line string)) error {
+
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			handle(strings.TrimRight(line, "\r\n"))
+		}
+
+		if err != nil {
+			// End-of-file (EOF) are treated as errors in Go io operations, so we need
+			// to make the distinction. A pipe closed by a killed process ends the output too.
+			if err == io.EOF || err == io.ErrClosedPipe || err == io.ErrUnexpectedEOF || err == os.ErrClosed {
+				return nil
+			}
+			if pathErr, ok := err.(*os.PathError); ok && pathErr.Err == os.ErrClosed {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// printOutputLine echoes a line of CasperJS output to the console, marking the stderr ones
+func printOutputLine(c *CasperTest, stream string, line string) {
+
+	if stream == streamStderr {
+		fmt.Printf("[%s] CasperJS stderr: %s\n", c.Key(), line)
+		return
+	}
+	fmt.Printf("[%s] CasperJS: %s\n", c.Key(), line)
+}
+
+// writeOutputLog writes both output streams of an attempt to the file at path,
+// one "<time> <stream> | <text>" line per output line, in the order they were received
+func writeOutputLog(path string, lines []*OutputLine) error {
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(file)
+	for _, line := range lines {
+		fmt.Fprintf(writer, "%s %s | %s\n", line.At.Format(outputLogTimeFormat), line.Stream, line.Text)
+	}
+
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// parseOutputLogLine splits a line of an output log file into its stream and text.
+// Lines in any other format are taken as plain stdout output.
+func parseOutputLogLine(line string) (stream string, text string) {
+
+	if m := outputLogLineRegex.FindStringSubmatch(line); m != nil {
+		return m[1], m[2]
+	}
+	return streamStdout, line
+}
+
+// lineWriter is an io.Writer calling handle for every complete line written to it
+type lineWriter struct {
+	handle  func(line string)
+	pending bytes.Buffer
+}
+
+func (w *lineWriter) Write(b []byte) (int, error) {
+
+	w.pending.Write(b)
+	for {
+		i := bytes.IndexByte(w.pending.Bytes(), '\n')
+		if i < 0 {
+			return len(b), nil
+		}
+		line := string(w.pending.Next(i + 1))
+		w.handle(strings.TrimRight(line, "\r\n"))
+	}
+}
+
+// Flush passes on the last line, when it did not end with a newline
+func (w *lineWriter) Flush() {
+
+	if w.pending.Len() > 0 {
+		w.handle(strings.TrimRight(w.pending.String(), "\r"))
+		w.pending.Reset()
+	}
+}
@@ -0,0 +1,96 @@
+package casperjs
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadLines(t *testing.T) {
+
+	long := strings.Repeat("x", 2*bufio.MaxScanTokenSize)
+	got := make([]string, 0)
+	err := readLines(strings.NewReader("first\r\n"+long+"\n\nlast"), func(line string) {
+		got = append(got, line)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"first", long, "", "last"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %d lines, want the long line and the last one without a newline kept", len(got))
+	}
+}
+
+func TestLineWriter(t *testing.T) {
+
+	got := make([]string, 0)
+	w := &lineWriter{handle: func(line string) { got = append(got, line) }}
+	for _, chunk := range []string{"PASS ti", "tle\nFAIL link\r\n", "trailing"} {
+		w.Write([]byte(chunk))
+	}
+	if want := []string{"PASS title", "FAIL link"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q before flushing, want %q", got, want)
+	}
+	w.Flush()
+	if len(got) != 3 || got[2] != "trailing" {
+		t.Errorf("got %q after flushing, want the trailing line passed on", got)
+	}
+}
+
+func TestOutputLogRoundTrip(t *testing.T) {
+
+	at := time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC)
+	parser := newOutputParser(at)
+	parser.parseStreamLine(streamStdout, "# Home page", at)
+	parser.parseStreamLine(streamStderr, "TypeError: undefined is not a function | stack", at)
+	parser.parseStreamLine(streamStdout, "PASS 1 test executed in 0.5s, 1 passed, 0 failed, 0 dubious, 0 skipped.", at)
+	result := parser.finish(at)
+
+	if result.Status != StatusPassed || len(result.Output) != 2 || len(result.Stderr) != 1 || len(result.Lines) != 3 {
+		t.Fatalf("got status %s with %d stdout, %d stderr and %d interleaved lines",
+			result.Status, len(result.Output), len(result.Stderr), len(result.Lines))
+	}
+
+	path := filepath.Join(t.TempDir(), outputLogName)
+	if err := writeOutputLog(path, result.Lines); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	i := 0
+	err = readLines(file, func(line string) {
+		stream, text := parseOutputLogLine(line)
+		if want := result.Lines[i]; stream != want.Stream || text != want.Text {
+			t.Errorf("line %d: got %s %q, want %s %q", i, stream, text, want.Stream, want.Text)
+		}
+		i++
+	})
+	if err != nil || i != len(result.Lines) {
+		t.Errorf("read %d lines (error %v), want %d", i, err, len(result.Lines))
+	}
+
+	if stream, text := parseOutputLogLine("PASS plain output"); stream != streamStdout || text != "PASS plain output" {
+		t.Errorf("got %s %q for a plain output line", stream, text)
+	}
+}
+
+func TestMarkTimedOutRecordsRunnerLine(t *testing.T) {
+
+	parser := newOutputParser(time.Now())
+	parser.parseLine("PASS title matches", time.Now())
+	parser.markTimedOut(2 * time.Second)
+	result := parser.finish(time.Now())
+
+	last := result.Lines[len(result.Lines)-1]
+	if last.Stream != streamRunner || last.Text != "timed out after 2s" {
+		t.Errorf("got last line %s %q, want the timeout recorded for replays", last.Stream, last.Text)
+	}
+}
@@ -0,0 +1,195 @@
+package casperjs
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ParamSpec describes a runtime parameter declared by a script in MANIFEST_SCRIPT_PARAMS,
+// which the script reads through casper.cli
+type ParamSpec struct {
+	Name     string
+	Default  string
+	Required bool
+}
+
+// parseParamSpecs decodes the JSON form of a MANIFEST_SCRIPT_PARAMS object, where each
+// key is a parameter name and each value its default. A null value marks a required
+// parameter, which has no default.
+func parseParamSpecs(value string) ([]*ParamSpec, error) {
+
+	declared := make(map[string]interface{})
+	if err := json.Unmarshal([]byte(value), &declared); err != nil {
+		return nil, fmt.Errorf("expected an object of parameter defaults: %s", err)
+	}
+
+	specs := make([]*ParamSpec, 0, len(declared))
+	for name, defaultValue := range declared {
+		spec := &ParamSpec{Name: name}
+		switch v := defaultValue.(type) {
+		case nil:
+			spec.Required = true
+		case string:
+			spec.Default = v
+		case float64:
+			spec.Default = strconv.FormatFloat(v, 'f', -1, 64)
+		case bool:
+			spec.Default = strconv.FormatBool(v)
+		default:
+			return nil, fmt.Errorf("the default of parameter %q must be a string, number, boolean or null", name)
+		}
+		specs = append(specs, spec)
+	}
+
+	sort.Slice(specs, func(i, j int) bool { return specs[i].Name < specs[j].Name })
+	return specs, nil
+}
+
+// resolveParams merges the defaults declared by the test with the given runtime
+// parameters, which take precedence. An error lists the required parameters left
+// without a value.
+func (c *CasperTest) resolveParams(params map[string]string) (map[string]string, error) {
+
+	resolved := make(map[string]string)
+	missing := make([]string, 0)
+
+	for _, spec := range c.Params {
+		if value, given := params[spec.Name]; given {
+			resolved[spec.Name] = value
+		} else if spec.Required {
+			missing = append(missing, spec.Name)
+		} else {
+			resolved[spec.Name] = spec.Default
+		}
+	}
+
+	// Parameters the script does not declare are still passed along
+	for name, value := range params {
+		resolved[name] = value
+	}
+
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("missing required parameter(s) %s, use -param or -params-file",
+			strings.Join(missing, ", "))
+	}
+	return resolved, nil
+}
+
+// runParams returns the runtime parameters of the test: the ones of the run,
+// overridden by the ones of its matrix cell
+func (c *CasperTest) runParams(options *RunOptions) map[string]string {
+
+	if c.Cell == nil {
+		return options.Params
+	}
+
+	params := make(map[string]string)
+	for name, value := range options.Params {
+		params[name] = value
+	}
+	for name, value := range c.Cell.Params {
+		params[name] = value
+	}
+	return params
+}
+
+// CheckParams tells whether every required runtime parameter of the test is provided
+// by the options or by its matrix cell, so that missing ones are reported before running
+func (c *CasperTest) CheckParams(options *RunOptions) error {
+
+	_, err := c.resolveParams(c.runParams(options.orDefault()))
+	return err
+}
+
+// casperArgs builds the casperjs command line for the test, passing each resolved
+// parameter as a --name=value option that the script reads through casper.cli
+func (c *CasperTest) casperArgs(options *RunOptions) ([]string, error) {
+
+	resolved, err := c.resolveParams(c.runParams(options))
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(resolved))
+	for name := range resolved {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	args := []string{"test"}
+	for _, name := range names {
+		args = append(args, fmt.Sprintf("--%s=%s", name, resolved[name]))
+	}
+	// The script path must survive CasperJS running in the artifacts directory
+	scriptPath := c.FilePath
+	if c.workDir != "" {
+		if absPath, err := filepath.Abs(c.FilePath); err == nil {
+			scriptPath = absPath
+		}
+	}
+	return append(args, scriptPath), nil
+}
+
+// SplitParam splits a name=value pair
+func SplitParam(pair string) (string, string, error) {
+
+	separator := strings.Index(pair, "=")
+	if separator < 1 {
+		return "", "", fmt.Errorf("%q is not in the name=value form", pair)
+	}
+	return strings.TrimSpace(pair[:separator]), pair[separator+1:], nil
+}
+
+// LoadParamsFile reads the parameters of an environment from the file at path. Files
+// with a .json extension hold a single object of strings, numbers or booleans; any
+// other file holds name=value lines, where blank lines and lines starting with # are ignored.
+func LoadParamsFile(path string) (map[string]string, error) {
+
+	params := make(map[string]string)
+
+	if strings.HasSuffix(strings.ToLower(path), ".json") {
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		specs, err := parseParamSpecs(string(contents))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", path, err)
+		}
+		for _, spec := range specs {
+			params[spec.Name] = spec.Default
+		}
+		return params, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	lineNumber := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		name, value, err := SplitParam(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %s", path, lineNumber, err)
+		}
+		params[name] = value
+	}
+
+	return params, scanner.Err()
+}
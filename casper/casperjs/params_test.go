@@ -0,0 +1,94 @@
+package casperjs
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseParamSpecs(t *testing.T) {
+
+	specs, err := parseParamSpecs(`{"user": "guest", "targetUrl": null, "retries": 2, "headless": true}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []*ParamSpec{
+		{Name: "headless", Default: "true"},
+		{Name: "retries", Default: "2"},
+		{Name: "targetUrl", Required: true},
+		{Name: "user", Default: "guest"},
+	}
+	if !reflect.DeepEqual(specs, want) {
+		t.Errorf("got %+v, want %+v", specs, want)
+	}
+
+	for _, value := range []string{`["user"]`, `{"user": ["a", "b"]}`} {
+		if _, err := parseParamSpecs(value); err == nil {
+			t.Errorf("%s: got no error", value)
+		}
+	}
+}
+
+func TestResolveParams(t *testing.T) {
+
+	c := &CasperTest{
+		FilePath: "checkout.js",
+		Params:   []*ParamSpec{{Name: "targetUrl", Required: true}, {Name: "user", Default: "guest"}},
+	}
+
+	if _, err := c.resolveParams(nil); err == nil || !strings.Contains(err.Error(), "targetUrl") {
+		t.Errorf("got error %v, want the missing targetUrl reported", err)
+	}
+
+	resolved, err := c.resolveParams(map[string]string{"targetUrl": "http://shop", "locale": "fr"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"targetUrl": "http://shop", "user": "guest", "locale": "fr"}
+	if !reflect.DeepEqual(resolved, want) {
+		t.Errorf("got %v, want %v", resolved, want)
+	}
+
+	args, err := c.casperArgs(&RunOptions{Params: map[string]string{"targetUrl": "http://shop", "user": "admin"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantArgs := []string{"test", "--targetUrl=http://shop", "--user=admin", "checkout.js"}
+	if !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("got arguments %q, want %q", args, wantArgs)
+	}
+}
+
+func TestLoadParamsFile(t *testing.T) {
+
+	dir := writeFiles(t, map[string]string{
+		"staging.env":  "# Staging\ntargetUrl=http://staging\n\nuser=qa=admin\n",
+		"staging.json": `{"targetUrl": "http://staging", "port": 8080}`,
+		"broken.env":   "targetUrl=http://staging\nheadless\n",
+	})
+
+	params, err := LoadParamsFile(filepath.Join(dir, "staging.env"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"targetUrl": "http://staging", "user": "qa=admin"}
+	if !reflect.DeepEqual(params, want) {
+		t.Errorf("got %q, want %q", params, want)
+	}
+
+	params, err = LoadParamsFile(filepath.Join(dir, "staging.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = map[string]string{"targetUrl": "http://staging", "port": "8080"}
+	if !reflect.DeepEqual(params, want) {
+		t.Errorf("got %q, want %q", params, want)
+	}
+
+	_, err = LoadParamsFile(filepath.Join(dir, "broken.env"))
+	if err == nil || !strings.HasSuffix(err.Error(), `broken.env:2: "headless" is not in the name=value form`) {
+		t.Errorf("got error %v, want the offending line reported", err)
+	}
+}
@@ -0,0 +1,101 @@
+package casperjs
+
+import (
+	"context"
+	"log"
+	"sync"
+)
+
+// RunTests runs the given Casper tests through a pool of at most options.Parallelism
+// concurrent workers, in the order of their dependencies: a test is only started once
+// all of its prerequisites have passed, and is skipped if any of them did not. Tests
+// that do not depend on each other run in parallel. It only returns once every worker
+// has finished. Once ctx is done, the running tests are stopped, and the tests not
+// started yet are not run at all, being left without a result. Nil options stand
+// for the default ones. The results of a previous run of the tests are cleared first.
+func RunTests(ctx context.Context, tests []*CasperTest, options *RunOptions) {
+
+	options = options.orDefault()
+	for _, t := range tests {
+		t.Result, t.Attempts = nil, nil
+	}
+
+	graph, err := newDependencyGraph(tests)
+	if err != nil {
+		log.Println("Not running the Casper tests: ", err)
+		return
+	}
+
+	workerCount := options.Parallelism
+	if workerCount < 1 {
+		workerCount = 1
+	}
+	if workerCount > len(tests) {
+		workerCount = len(tests)
+	}
+
+	log.Printf("Running %d Casper tests using %d worker(s)", len(tests), workerCount)
+
+	queue := make(chan *CasperTest)
+	done := make(chan *CasperTest)
+	var wg sync.WaitGroup
+
+	for i := 0; i < workerCount; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for t := range queue {
+				t.Run(ctx, options)
+				done <- t
+			}
+		}()
+	}
+
+	ready := graph.roots(tests)
+	running, completed := 0, 0
+	cancelled := ctx.Done()
+	stopped := false
+
+	for completed < len(tests) && !(stopped && running == 0) {
+
+		// The context may be done while a test completes, in which case both channels are
+		// ready at once, so it is checked first to never start another test after cancellation
+		if !stopped && ctx.Err() != nil {
+			log.Println("Run cancelled, not scheduling the remaining Casper tests")
+			stopped = true
+			cancelled = nil
+			continue
+		}
+
+		// Only offer a test to the workers when one is ready and the run goes on
+		var next *CasperTest
+		var workers chan *CasperTest
+		if len(ready) > 0 && !stopped {
+			next, workers = ready[0], queue
+		}
+
+		select {
+		case workers <- next:
+			ready = ready[1:]
+			running++
+		case t := <-done:
+			running--
+			completed++
+			if stopped || ctx.Err() != nil {
+				// The dependents of a cancelled test are left without a result, rather than skipped
+				continue
+			}
+			unlocked, skipped := graph.complete(t)
+			ready = append(ready, unlocked...)
+			completed += len(skipped)
+		case <-cancelled:
+			log.Println("Run cancelled, not scheduling the remaining Casper tests")
+			stopped = true
+			cancelled = nil
+		}
+	}
+	close(queue)
+
+	// wait for the in-flight tests to complete before returning
+	wg.Wait()
+}
@@ -0,0 +1,280 @@
+package casperjs
+
+import (
+	"context"
+	"testing"
+)
+
+// timedOutOutput is the output log of an attempt stopped for exceeding its time limit
+const timedOutOutput = "2026-01-02T15:04:05.000Z stdout | # Home page\n" +
+	"2026-01-02T15:04:05.100Z stdout | PASS title matches\n" +
+	"2026-01-02T15:04:07.000Z runner | timed out after 2s\n"
+
+// statusOf returns the status of the test, or "none" when it has no result
+func statusOf(c *CasperTest) TestStatus {
+
+	if c.Result == nil {
+		return "none"
+	}
+	return c.Result.Status
+}
+
+func TestRunTestsReplay(t *testing.T) {
+
+	cases := []struct {
+		name         string
+		tests        []*CasperTest
+		recordings   map[string]string
+		retries      int
+		wantStatus   map[string]TestStatus
+		wantAttempts map[string][]TestStatus
+	}{
+		{
+			name:         "pass",
+			tests:        []*CasperTest{{Id: "a", Name: "a"}},
+			recordings:   map[string]string{"a.log": passedOutput},
+			wantStatus:   map[string]TestStatus{"a": StatusPassed},
+			wantAttempts: map[string][]TestStatus{"a": {StatusPassed}},
+		},
+		{
+			name:         "fail",
+			tests:        []*CasperTest{{Id: "a", Name: "a"}},
+			recordings:   map[string]string{"a.log": failedOutput},
+			wantStatus:   map[string]TestStatus{"a": StatusFailed},
+			wantAttempts: map[string][]TestStatus{"a": {StatusFailed}},
+		},
+		{
+			name:         "fail after every retry",
+			tests:        []*CasperTest{{Id: "a", Name: "a"}},
+			recordings:   map[string]string{"a.log": failedOutput},
+			retries:      2,
+			wantStatus:   map[string]TestStatus{"a": StatusFailed},
+			wantAttempts: map[string][]TestStatus{"a": {StatusFailed, StatusFailed, StatusFailed}},
+		},
+		{
+			name:  "retry turns flaky",
+			tests: []*CasperTest{{Id: "a", Name: "a"}},
+			recordings: map[string]string{
+				"a.log":           failedOutput,
+				"a.attempt-2.log": passedOutput,
+			},
+			retries:      2,
+			wantStatus:   map[string]TestStatus{"a": StatusFlaky},
+			wantAttempts: map[string][]TestStatus{"a": {StatusFailed, StatusPassed}},
+		},
+		{
+			name:  "retry after timeout from an artifacts run directory",
+			tests: []*CasperTest{{Id: "a", Name: "a"}},
+			recordings: map[string]string{
+				"a/casperjs.log":           timedOutOutput,
+				"a.attempt-2/casperjs.log": passedOutput,
+			},
+			retries:      1,
+			wantStatus:   map[string]TestStatus{"a": StatusFlaky},
+			wantAttempts: map[string][]TestStatus{"a": {StatusTimedOut, StatusPassed}},
+		},
+		{
+			name:  "artifacts run directory",
+			tests: []*CasperTest{{Id: "a", Name: "a"}},
+			recordings: map[string]string{
+				"a/casperjs.log": "2026-01-02T15:04:05.000Z stdout | # Home page\n" +
+					"2026-01-02T15:04:05.100Z stderr | Unsafe JavaScript attempt\n" +
+					"2026-01-02T15:04:05.200Z stdout | PASS 1 test executed in 0.2s, 1 passed, 0 failed, 0 dubious, 0 skipped.\n",
+			},
+			wantStatus: map[string]TestStatus{"a": StatusPassed},
+		},
+		{
+			name: "failed prerequisite skips its dependents",
+			tests: []*CasperTest{
+				{Id: "a", Name: "a"},
+				{Id: "b", Name: "b", Depends: []string{"a"}},
+				{Id: "c", Name: "c", Depends: []string{"b"}},
+				{Id: "d", Name: "d"},
+			},
+			recordings: map[string]string{
+				"a.log": failedOutput,
+				"b.log": passedOutput,
+				"c.log": passedOutput,
+				"d.log": passedOutput,
+			},
+			wantStatus: map[string]TestStatus{"a": StatusFailed, "b": StatusSkipped, "c": StatusSkipped, "d": StatusPassed},
+			wantAttempts: map[string][]TestStatus{
+				"a": {StatusFailed},
+				"b": nil,
+				"c": nil,
+				"d": {StatusPassed},
+			},
+		},
+		{
+			name: "passed prerequisite runs its dependents",
+			tests: []*CasperTest{
+				{Id: "b", Name: "b", Depends: []string{"a"}},
+				{Id: "a", Name: "a"},
+			},
+			recordings: map[string]string{"a.log": passedOutput, "b.log": passedOutput},
+			wantStatus: map[string]TestStatus{"a": StatusPassed, "b": StatusPassed},
+		},
+		{
+			name:       "missing recording",
+			tests:      []*CasperTest{{Id: "a", Name: "a"}},
+			wantStatus: map[string]TestStatus{"a": StatusError},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			options := &RunOptions{
+				Parallelism: 2,
+				Retries:     tc.retries,
+				Runner:      &ReplayRunner{Dir: writeFiles(t, tc.recordings)},
+			}
+			RunTests(context.Background(), tc.tests, options)
+
+			for _, c := range tc.tests {
+				if got := statusOf(c); got != tc.wantStatus[c.Id] {
+					t.Errorf("test %s: got status %s, want %s", c.Id, got, tc.wantStatus[c.Id])
+				}
+
+				wantAttempts, checked := tc.wantAttempts[c.Id]
+				if !checked {
+					continue
+				}
+				if len(c.Attempts) != len(wantAttempts) {
+					t.Errorf("test %s: got %d attempts, want %d", c.Id, len(c.Attempts), len(wantAttempts))
+					continue
+				}
+				for i, attempt := range c.Attempts {
+					if attempt.Attempt != i+1 || attempt.Status != wantAttempts[i] {
+						t.Errorf("test %s: got attempt %d %s, want attempt %d %s",
+							c.Id, attempt.Attempt, attempt.Status, i+1, wantAttempts[i])
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestRunTestsTwice(t *testing.T) {
+
+	tests := []*CasperTest{
+		{Id: "a", Name: "a"},
+		{Id: "b", Name: "b", Depends: []string{"a"}},
+	}
+
+	// The first run skips b, which must not be taken as already done by the second one
+	failing := writeFiles(t, map[string]string{"a.log": failedOutput, "b.log": passedOutput})
+	RunTests(context.Background(), tests, &RunOptions{Retries: 1, Runner: &ReplayRunner{Dir: failing}})
+	if got := statusOf(tests[1]); got != StatusSkipped {
+		t.Fatalf("first run: got status %s for b, want %s", got, StatusSkipped)
+	}
+
+	passing := writeFiles(t, map[string]string{"a.log": passedOutput, "b.log": passedOutput})
+	RunTests(context.Background(), tests, &RunOptions{Runner: &ReplayRunner{Dir: passing}})
+	for _, c := range tests {
+		if got := statusOf(c); got != StatusPassed || len(c.Attempts) != 1 {
+			t.Errorf("second run: got status %s and %d attempts for %s, want a single passed attempt", got, len(c.Attempts), c.Id)
+		}
+	}
+}
+
+// sequenceRunner replays the given outputs, one per attempt, repeating the last one
+type sequenceRunner struct {
+	outputs []string
+	runs    int
+}
+
+func (r *sequenceRunner) Run(ctx context.Context, c *CasperTest, options *RunOptions) *TestResult {
+
+	output := r.outputs[len(r.outputs)-1]
+	if r.runs < len(r.outputs) {
+		output = r.outputs[r.runs]
+	}
+	r.runs++
+	return parseOutput(output)
+}
+
+func TestRunFlaky(t *testing.T) {
+
+	c := &CasperTest{Id: "a", Name: "a"}
+	c.Run(context.Background(), &RunOptions{Retries: 2, Runner: &sequenceRunner{outputs: []string{failedOutput, passedOutput}}})
+
+	if c.Result.Status != StatusFlaky {
+		t.Errorf("got status %s, want %s", c.Result.Status, StatusFlaky)
+	}
+	if len(c.Attempts) != 2 || c.Attempts[0].Status != StatusFailed || c.Attempts[1].Status != StatusPassed {
+		t.Errorf("got %d attempts, want a failed one followed by a passed one", len(c.Attempts))
+	}
+}
+
+// cancellingRunner cancels the run as soon as the test with the given id starts,
+// then replays it, as if the run was interrupted while that test was running
+type cancellingRunner struct {
+	replay *ReplayRunner
+	id     string
+	cancel context.CancelFunc
+}
+
+func (r *cancellingRunner) Run(ctx context.Context, c *CasperTest, options *RunOptions) *TestResult {
+
+	if c.Id == r.id {
+		r.cancel()
+	}
+	return r.replay.Run(ctx, c, options)
+}
+
+func TestRunTestsCancelled(t *testing.T) {
+
+	tests := []*CasperTest{
+		{Id: "a", Name: "a"},
+		{Id: "b", Name: "b", Depends: []string{"a"}},
+		{Id: "d", Name: "d"},
+	}
+	dir := writeFiles(t, map[string]string{"a.log": failedOutput, "b.log": passedOutput, "d.log": passedOutput})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	options := &RunOptions{
+		Retries: 2,
+		Runner:  &cancellingRunner{replay: &ReplayRunner{Dir: dir}, id: "a", cancel: cancel},
+	}
+	RunTests(ctx, tests, options)
+
+	// The cancelled test is not retried, and neither its dependent nor the test
+	// waiting for a worker get to run or get skipped
+	want := map[string]TestStatus{"a": StatusCancelled, "b": "none", "d": "none"}
+	for _, c := range tests {
+		if got := statusOf(c); got != want[c.Id] {
+			t.Errorf("test %s: got status %s, want %s", c.Id, got, want[c.Id])
+		}
+	}
+	if len(tests[0].Attempts) != 1 {
+		t.Errorf("cancelled test made %d attempts, want 1", len(tests[0].Attempts))
+	}
+}
+
+func TestRunTestsAlreadyCancelled(t *testing.T) {
+
+	tests := []*CasperTest{{Id: "a", Name: "a"}}
+	dir := writeFiles(t, map[string]string{"a.log": passedOutput})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	RunTests(ctx, tests, &RunOptions{Runner: &ReplayRunner{Dir: dir}})
+
+	if got := statusOf(tests[0]); got != "none" {
+		t.Errorf("got status %s, want no result", got)
+	}
+}
+
+func TestRunTestsNilOptions(t *testing.T) {
+
+	// Without casperjs on the path, the default runner reports an error rather than panicking
+	t.Setenv("PATH", "")
+	tests := []*CasperTest{{Id: "a", Name: "a", FilePath: "a.js"}}
+	RunTests(context.Background(), tests, nil)
+
+	if got := statusOf(tests[0]); got != StatusError {
+		t.Errorf("got status %s, want %s", got, StatusError)
+	}
+}
@@ -0,0 +1,24 @@
+//go:build !windows
+// +build !windows
+
+package casperjs
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup makes the command the leader of a new process group, so that
+// the phantomjs processes spawned by casperjs can be terminated along with it
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// killProcessGroup kills the started command along with every process in its group
+func killProcessGroup(cmd *exec.Cmd) error {
+
+	if cmd.Process == nil {
+		return nil
+	}
+	return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
@@ -0,0 +1,17 @@
+package casperjs
+
+import (
+	"os/exec"
+)
+
+// setProcessGroup is a no-op on Windows, which has no process groups in the Unix sense
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup kills the started command. Child processes are not tracked on Windows.
+func killProcessGroup(cmd *exec.Cmd) error {
+
+	if cmd.Process == nil {
+		return nil
+	}
+	return cmd.Process.Kill()
+}
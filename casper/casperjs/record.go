@@ -0,0 +1,40 @@
+package casperjs
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"time"
+)
+
+// RunRecord holds the tests of a complete run along with their results,
+// as saved to, and loaded from, a JSON results file
+type RunRecord struct {
+	StartedAt  time.Time
+	FinishedAt time.Time
+	Tests      []*CasperTest
+}
+
+// SaveRunRecord writes the run record as indented JSON to the file at path
+func SaveRunRecord(path string, record *RunRecord) error {
+
+	contents, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, contents, 0644)
+}
+
+// LoadRunRecord reads a run record previously written by SaveRunRecord
+func LoadRunRecord(path string) (*RunRecord, error) {
+
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	record := &RunRecord{}
+	if err := json.Unmarshal(contents, record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
@@ -0,0 +1,300 @@
+package casperjs
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TestStatus describes the overall outcome of running a CasperTest
+type TestStatus string
+
+const (
+	StatusUnknown  TestStatus = "unknown"
+	StatusPassed   TestStatus = "passed"
+	StatusFailed   TestStatus = "failed"
+	StatusError    TestStatus = "error"
+	StatusTimedOut TestStatus = "timedout"
+	// StatusFlaky marks a test that passed after one or more failed attempts
+	StatusFlaky TestStatus = "flaky"
+	// StatusSkipped marks a test that was not run because a prerequisite did not pass
+	StatusSkipped TestStatus = "skipped"
+	// StatusCancelled marks a test stopped before completion because the run was interrupted.
+	// It says nothing about the test itself, so it is left out of the history and JUnit reports.
+	StatusCancelled TestStatus = "cancelled"
+)
+
+// AssertionStatus is the verdict CasperJS printed for a single assertion
+type AssertionStatus string
+
+const (
+	AssertionPassed  AssertionStatus = "PASS"
+	AssertionFailed  AssertionStatus = "FAIL"
+	AssertionSkipped AssertionStatus = "SKIP"
+)
+
+// Assertion holds a single PASS/FAIL/SKIP line reported by CasperJS, along with
+// the "#    key: value" detail lines that may follow it
+type Assertion struct {
+	Status  AssertionStatus
+	Message string
+	Suite   string
+	// Context is the most recent test.info message printed before the assertion,
+	// for example the viewport currently in effect
+	Context string
+	Details map[string]string
+	// Duration is the time elapsed since the previous assertion (or the start of the test)
+	Duration time.Duration
+}
+
+// Summary mirrors the final "N tests executed in Xs, ..." line printed by CasperJS
+type Summary struct {
+	Executed int
+	Passed   int
+	Failed   int
+	Dubious  int
+	Skipped  int
+	Duration time.Duration
+}
+
+// TestResult is the structured outcome of a CasperTest run, built from the CasperJS output
+type TestResult struct {
+	Status     TestStatus
+	Attempt    int
+	Suites     []string
+	Assertions []*Assertion
+	Messages   []string
+	Summary    *Summary
+	// Output holds the stdout lines of CasperJS, and Stderr its stderr lines
+	Output []string
+	Stderr []string `json:",omitempty"`
+	// Lines holds both streams, interleaved in the order they were received, with timestamps
+	Lines []*OutputLine `json:",omitempty"`
+	// Errors lists the problems encountered by the runner itself, as opposed to failed assertions
+	Errors []string
+	// ArtifactDir is the working directory CasperJS ran in, and Artifacts the files it produced there
+	ArtifactDir string
+	Artifacts   []*Artifact
+	// SkipReason explains why a test with the skipped status was not run
+	SkipReason string `json:",omitempty"`
+	// VisualDiffs holds the comparisons of the screenshots against their baselines
+	VisualDiffs []*VisualDiff
+	StartedAt   time.Time
+	FinishedAt  time.Time
+	Duration    time.Duration
+}
+
+// Count returns the number of assertions with the given status
+func (r *TestResult) Count(status AssertionStatus) int {
+
+	count := 0
+	for _, a := range r.Assertions {
+		if a.Status == status {
+			count++
+		}
+	}
+	return count
+}
+
+var (
+	ansiEscapeRegex = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+	summaryRegex    = regexp.MustCompile(`^(PASS|FAIL) (\d+) tests? executed in ([\d.]+)s, (\d+) passed, (\d+) failed, (\d+) dubious, (\d+) skipped`)
+	suiteDoneRegex  = regexp.MustCompile(`^(PASS|FAIL) (.*) \((\d+) tests?\)$`)
+	assertionRegex  = regexp.MustCompile(`^(PASS|FAIL|SKIP) (.*)$`)
+	detailRegex     = regexp.MustCompile(`^#\s{2,}([\w-]+):\s?(.*)$`)
+	suiteRegex      = regexp.MustCompile(`^# (.*)$`)
+)
+
+// outputParser incrementally turns CasperJS test output lines into a TestResult
+type outputParser struct {
+	result        *TestResult
+	lastEvent     time.Time
+	lastInfo      string
+	lastAssertion *Assertion
+	currentSuite  string
+	summaryDone   bool
+	timedOut      bool
+	cancelled     bool
+	mu            sync.Mutex
+}
+
+// newOutputParser creates a parser whose result starts at the given moment
+func newOutputParser(startedAt time.Time) *outputParser {
+
+	return &outputParser{
+		result:    &TestResult{Status: StatusUnknown, StartedAt: startedAt},
+		lastEvent: startedAt,
+	}
+}
+
+// parseStreamLine records a line received on either stream at the given moment.
+// Only the stdout lines get classified, the stderr ones being kept as they are.
+func (p *outputParser) parseStreamLine(stream string, rawLine string, at time.Time) {
+
+	if stream != streamStderr {
+		p.parseLine(rawLine, at)
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.result.Stderr = append(p.result.Stderr, rawLine)
+	p.result.Lines = append(p.result.Lines, &OutputLine{At: at, Stream: streamStderr, Text: rawLine})
+}
+
+// parseLine classifies a single line of CasperJS stdout output, received at the given moment
+func (p *outputParser) parseLine(rawLine string, at time.Time) {
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.result.Output = append(p.result.Output, rawLine)
+	p.result.Lines = append(p.result.Lines, &OutputLine{At: at, Stream: streamStdout, Text: rawLine})
+	line := strings.TrimRight(ansiEscapeRegex.ReplaceAllString(rawLine, ""), " \t\r")
+
+	if line == "" {
+		return
+	}
+
+	// Everything after the summary (e.g. "Details for the N failed tests") is informative only
+	if p.summaryDone {
+		p.result.Messages = append(p.result.Messages, line)
+		return
+	}
+
+	if m := summaryRegex.FindStringSubmatch(line); m != nil {
+		p.result.Summary = parseSummary(m)
+		p.summaryDone = true
+		p.lastAssertion = nil
+		return
+	}
+
+	if m := suiteDoneRegex.FindStringSubmatch(line); m != nil {
+		// Closing line of a casper.test.begin() suite, not an assertion
+		p.lastAssertion = nil
+		return
+	}
+
+	if m := assertionRegex.FindStringSubmatch(line); m != nil {
+		assertion := &Assertion{
+			Status:   AssertionStatus(m[1]),
+			Message:  m[2],
+			Suite:    p.currentSuite,
+			Context:  p.lastInfo,
+			Duration: at.Sub(p.lastEvent),
+		}
+		p.result.Assertions = append(p.result.Assertions, assertion)
+		p.lastAssertion = assertion
+		p.lastEvent = at
+		return
+	}
+
+	if m := detailRegex.FindStringSubmatch(line); m != nil && p.lastAssertion != nil {
+		if p.lastAssertion.Details == nil {
+			p.lastAssertion.Details = make(map[string]string)
+		}
+		p.lastAssertion.Details[m[1]] = m[2]
+		return
+	}
+
+	if m := suiteRegex.FindStringSubmatch(line); m != nil {
+		p.currentSuite = m[1]
+		p.result.Suites = append(p.result.Suites, m[1])
+		p.lastAssertion = nil
+		return
+	}
+
+	// Anything else is a test.info/echo message. Separator lines made of dashes
+	// are kept as messages but do not replace the current context.
+	p.result.Messages = append(p.result.Messages, line)
+	if strings.Trim(line, "-=* ") != "" && !strings.HasPrefix(line, "Test file:") {
+		p.lastInfo = line
+	}
+	p.lastAssertion = nil
+}
+
+// addError records a runner error against the result
+func (p *outputParser) addError(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.result.Errors = append(p.result.Errors, err.Error())
+}
+
+// summaryReportsFailures tells whether CasperJS printed a summary containing
+// failed or dubious tests, which explains a non-zero exit code
+func (p *outputParser) summaryReportsFailures() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	summary := p.result.Summary
+	return summary != nil && summary.Passed < summary.Executed
+}
+
+// markTimedOut records that the test was stopped after exceeding the given limit
+func (p *outputParser) markTimedOut(limit time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.timedOut = true
+	p.result.Messages = append(p.result.Messages, fmt.Sprintf("Timed out after %s", limit))
+	p.result.Lines = append(p.result.Lines, &OutputLine{At: time.Now(), Stream: streamRunner, Text: timeoutMarker + limit.String()})
+}
+
+// markCancelled records that the test was stopped before completion, because its
+// context was done for the given reason, for example when the run was interrupted by a signal
+func (p *outputParser) markCancelled(reason error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.cancelled = true
+	p.result.Messages = append(p.result.Messages, fmt.Sprintf("Cancelled before completion: %s", reason))
+}
+
+// finish closes the result at the given moment and determines its overall status
+func (p *outputParser) finish(at time.Time) *TestResult {
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	r := p.result
+	r.FinishedAt = at
+	r.Duration = at.Sub(r.StartedAt)
+
+	if r.Summary == nil && len(r.Errors) == 0 && !p.timedOut && !p.cancelled {
+		r.Errors = append(r.Errors, "no test summary found in the CasperJS output")
+	}
+
+	switch {
+	case p.cancelled:
+		r.Status = StatusCancelled
+	case p.timedOut:
+		r.Status = StatusTimedOut
+	case len(r.Errors) > 0:
+		r.Status = StatusError
+	case r.Count(AssertionFailed) > 0 || r.Summary.Failed > 0:
+		r.Status = StatusFailed
+	default:
+		r.Status = StatusPassed
+	}
+
+	return r
+}
+
+// parseSummary converts the submatches of summaryRegex into a Summary
+func parseSummary(m []string) *Summary {
+
+	atoi := func(s string) int {
+		n, _ := strconv.Atoi(s)
+		return n
+	}
+
+	seconds, _ := strconv.ParseFloat(m[3], 64)
+	return &Summary{
+		Executed: atoi(m[2]),
+		Passed:   atoi(m[4]),
+		Failed:   atoi(m[5]),
+		Dubious:  atoi(m[6]),
+		Skipped:  atoi(m[7]),
+		Duration: time.Duration(seconds * float64(time.Second)),
+	}
+}
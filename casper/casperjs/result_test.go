@@ -0,0 +1,146 @@
+package casperjs
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Recorded CasperJS outputs, shared by the tests of the package
+const (
+	passedOutput = "# Home page\n" +
+		"PASS title matches\n" +
+		"PASS 1 test executed in 0.5s, 1 passed, 0 failed, 0 dubious, 0 skipped.\n"
+	failedOutput = "# Home page\n" +
+		"PASS title matches\n" +
+		"FAIL link not found\n" +
+		"#    type: assert\n" +
+		"FAIL 2 tests executed in 1.2s, 1 passed, 1 failed, 0 dubious, 0 skipped.\n"
+)
+
+// parseOutput feeds the output lines to a new parser, one millisecond apart, and finishes it
+func parseOutput(output string) *TestResult {
+
+	start := time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC)
+	parser := newOutputParser(start)
+	at := start
+	for _, line := range strings.Split(output, "\n") {
+		at = at.Add(time.Millisecond)
+		parser.parseLine(line, at)
+	}
+	return parser.finish(at)
+}
+
+func TestOutputParserStatus(t *testing.T) {
+
+	cases := []struct {
+		name           string
+		output         string
+		wantStatus     TestStatus
+		wantAssertions int
+		wantFailed     int
+	}{
+		{"passed", passedOutput, StatusPassed, 1, 0},
+		{"failed", failedOutput, StatusFailed, 2, 1},
+		{"no summary", "# Home page\nPASS title matches", StatusError, 1, 0},
+		{"colored", "\x1b[32;1mPASS\x1b[0m title matches\n\x1b[42;30mPASS 1 test executed in 0.1s, 1 passed, 0 failed, 0 dubious, 0 skipped.\x1b[0m",
+			StatusPassed, 1, 0},
+		{"suite closing line", "# Home page\nPASS title matches\nPASS Home page (1 test)\nPASS 1 test executed in 0.1s, 1 passed, 0 failed, 0 dubious, 0 skipped.",
+			StatusPassed, 1, 0},
+		{"failures after summary", "FAIL 1 test executed in 0.1s, 0 passed, 1 failed, 0 dubious, 0 skipped.\nFAIL details of the failure",
+			StatusFailed, 0, 0},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			r := parseOutput(tc.output)
+			if r.Status != tc.wantStatus {
+				t.Errorf("got status %s, want %s", r.Status, tc.wantStatus)
+			}
+			if len(r.Assertions) != tc.wantAssertions {
+				t.Errorf("got %d assertions, want %d", len(r.Assertions), tc.wantAssertions)
+			}
+			if got := r.Count(AssertionFailed); got != tc.wantFailed {
+				t.Errorf("got %d failed assertions, want %d", got, tc.wantFailed)
+			}
+		})
+	}
+}
+
+func TestOutputParserAssertions(t *testing.T) {
+
+	r := parseOutput("Test file: samples/home.js\n" +
+		"# Home page\n" +
+		"Current viewport: mobile(375,667)\n" +
+		"PASS title matches\n" +
+		"FAIL link not found\n" +
+		"#    type: assertExists\n" +
+		"#    subject: false\n" +
+		"SKIP 1 test skipped\n" +
+		"FAIL 3 tests executed in 1.2s, 1 passed, 1 failed, 0 dubious, 1 skipped.")
+
+	if len(r.Suites) != 1 || r.Suites[0] != "Home page" {
+		t.Errorf("got suites %q, want [Home page]", r.Suites)
+	}
+	if len(r.Assertions) != 3 {
+		t.Fatalf("got %d assertions, want 3", len(r.Assertions))
+	}
+
+	failed := r.Assertions[1]
+	if failed.Status != AssertionFailed || failed.Message != "link not found" {
+		t.Errorf("got assertion %s %q, want FAIL \"link not found\"", failed.Status, failed.Message)
+	}
+	if failed.Suite != "Home page" || failed.Context != "Current viewport: mobile(375,667)" {
+		t.Errorf("got suite %q and context %q", failed.Suite, failed.Context)
+	}
+	if failed.Details["type"] != "assertExists" || failed.Details["subject"] != "false" {
+		t.Errorf("got details %v", failed.Details)
+	}
+	if failed.Duration != time.Millisecond {
+		t.Errorf("got duration %s, want 1ms", failed.Duration)
+	}
+
+	want := Summary{Executed: 3, Passed: 1, Failed: 1, Skipped: 1, Duration: 1200 * time.Millisecond}
+	if r.Summary == nil || *r.Summary != want {
+		t.Errorf("got summary %+v, want %+v", r.Summary, want)
+	}
+}
+
+func TestOutputParserRunnerError(t *testing.T) {
+
+	parser := newOutputParser(time.Now())
+	parser.parseLine("PASS 1 test executed in 0.1s, 1 passed, 0 failed, 0 dubious, 0 skipped.", time.Now())
+	parser.addError(errors.New("crashed"))
+
+	r := parser.finish(time.Now())
+	if r.Status != StatusError || len(r.Errors) != 1 {
+		t.Errorf("got status %s and errors %q, want %s with the runner error", r.Status, r.Errors, StatusError)
+	}
+}
+
+func TestOutputParserTimedOut(t *testing.T) {
+
+	parser := newOutputParser(time.Now())
+	parser.parseLine("PASS title matches", time.Now())
+	parser.markTimedOut(2 * time.Second)
+
+	r := parser.finish(time.Now())
+	if r.Status != StatusTimedOut || len(r.Errors) != 0 {
+		t.Errorf("got status %s and errors %q, want %s without errors", r.Status, r.Errors, StatusTimedOut)
+	}
+}
+
+func TestOutputParserCancelled(t *testing.T) {
+
+	parser := newOutputParser(time.Now())
+	parser.parseLine("FAIL link not found", time.Now())
+	parser.markCancelled(context.Canceled)
+
+	r := parser.finish(time.Now())
+	if r.Status != StatusCancelled || len(r.Errors) != 0 {
+		t.Errorf("got status %s and errors %q, want %s without errors", r.Status, r.Errors, StatusCancelled)
+	}
+}
@@ -0,0 +1,320 @@
+package casperjs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/pipe.v2"
+)
+
+// Runner launches a single attempt of a CasperTest, and turns its output into a result
+// Runners stop CasperJS as soon as ctx is done, recording the test as cancelled.
+type Runner interface {
+	Run(ctx context.Context, c *CasperTest, options *RunOptions) *TestResult
+}
+
+// RunnerNames lists the values accepted by the -runner flag
+var RunnerNames = []string{"stdlib", "pipe", "slimerjs", "replay"}
+
+// NewRunner returns the runner selected by name. The replay runner reads the
+// recorded outputs from replayDir.
+func NewRunner(name string, replayDir string) (Runner, error) {
+
+	switch name {
+	case "stdlib", "":
+		return &StandardLibRunner{}, nil
+	case "pipe":
+		return &PipeRunner{}, nil
+	case "slimerjs":
+		return &StandardLibRunner{Engine: "slimerjs"}, nil
+	case "replay":
+		if replayDir == "" {
+			return nil, fmt.Errorf("the replay runner needs a directory of recorded outputs")
+		}
+		return &ReplayRunner{Dir: replayDir}, nil
+	}
+	return nil, fmt.Errorf("unknown runner %q, expected one of %s", name, strings.Join(RunnerNames, ", "))
+}
+
+// runner returns the runner of the options, defaulting to a StandardLibRunner
+func (o *RunOptions) runner() Runner {
+
+	if o.Runner == nil {
+		return &StandardLibRunner{}
+	}
+	return o.Runner
+}
+
+// StandardLibRunner runs the casperjs command through os/exec, in its own process group
+type StandardLibRunner struct {
+	// Engine, when set, selects the browser engine of CasperJS, e.g. "slimerjs"
+	// to run the tests in Gecko rather than PhantomJS
+	Engine string
+}
+
+// casperArgs returns the casperjs arguments for the test, along with the engine if any
+func (s *StandardLibRunner) casperArgs(c *CasperTest, options *RunOptions) ([]string, error) {
+
+	args, err := c.casperArgs(options)
+	if err != nil || s.Engine == "" {
+		return args, err
+	}
+	// Engine options must come right after the "test" command
+	return append([]string{args[0], "--engine=" + s.Engine}, args[1:]...), nil
+}
+
+// Run launches CasperJS in test mode, using the Go standard library functionality.
+// The input file for Casper is provided by c.FilePath.
+// If the test exceeds its time limit, or ctx is done, the whole CasperJS process group is killed.
+func (s *StandardLibRunner) Run(ctx context.Context, c *CasperTest, options *RunOptions) (result *TestResult) {
+
+	options = options.orDefault()
+	log.Println("RunViaStandardLib - About to run test: ", c.Name)
+	parser := newOutputParser(time.Now())
+	defer func() { result = parser.finish(time.Now()) }()
+
+	casperArgs, err := s.casperArgs(c, options)
+	if err != nil {
+		log.Printf("Run() - Test %s - Error: %s", c.Name, err.Error())
+		parser.addError(err)
+		return
+	}
+
+	// The time limit of the test applies on top of the cancellation of the whole run
+	runCtx := ctx
+	timeout := c.timeoutFor(options)
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	casperCmd := exec.CommandContext(runCtx, "casperjs", casperArgs...)
+	casperCmd.Dir = c.workDir
+	setProcessGroup(casperCmd)
+	// Stopping CasperJS kills its whole process group, including the PhantomJS processes it started
+	casperCmd.Cancel = func() error {
+		log.Printf("Run() - Test %s - Stopping CasperJS: %s", c.Name, runCtx.Err())
+		return killProcessGroup(casperCmd)
+	}
+	// Both streams are split into lines as they are copied, so that no buffered output gets lost
+	stdOut := &lineWriter{handle: func(line string) {
+		printOutputLine(c, streamStdout, line)
+		parser.parseStreamLine(streamStdout, line, time.Now())
+	}}
+	stdErr := &lineWriter{handle: func(line string) {
+		printOutputLine(c, streamStderr, line)
+		parser.parseStreamLine(streamStderr, line, time.Now())
+	}}
+	casperCmd.Stdout = stdOut
+	casperCmd.Stderr = stdErr
+	casperCmd.WaitDelay = outputGracePeriod
+
+	err = casperCmd.Start()
+	if err != nil {
+		log.Printf("Run() - Test %s - casperCmd.Start() Error: %s", c.Name, err.Error())
+		parser.addError(err)
+		return
+	}
+
+	// wait for the command to cleanly execute. CasperJS exits with a non-zero
+	// code when assertions fail, which is not a runner error in itself.
+	err = casperCmd.Wait()
+	stdOut.Flush()
+	stdErr.Flush()
+	log.Printf("Run() - Test %s - Casper Output Ended", c.Name)
+	if errors.Is(err, exec.ErrWaitDelay) {
+		log.Printf("Run() - Test %s - CasperJS output still open after it exited, closed it", c.Name)
+		err = nil
+	}
+	if err != nil {
+		log.Printf("Run() - Test %s - casperCmd.Wait() Error: %s", c.Name, err.Error())
+		switch {
+		case ctx.Err() != nil:
+			parser.markCancelled(ctx.Err())
+		case runCtx.Err() != nil:
+			parser.markTimedOut(timeout)
+		default:
+			if _, isExitErr := err.(*exec.ExitError); !isExitErr || !parser.summaryReportsFailures() {
+				parser.addError(err)
+			}
+		}
+	}
+	return
+}
+
+// PipeRunner runs the casperjs command through the pipe package
+type PipeRunner struct{}
+
+// Run launches CasperJS in test mode, using the the pipe package
+// The input file for Casper is provided by c.FilePath.
+// If the test exceeds its time limit, or ctx is done, the whole CasperJS process group is killed.
+func (p *PipeRunner) Run(ctx context.Context, c *CasperTest, options *RunOptions) (result *TestResult) {
+
+	options = options.orDefault()
+	log.Println("RunViaPipe - About to run test: ", c.Name)
+	parser := newOutputParser(time.Now())
+	defer func() { result = parser.finish(time.Now()) }()
+
+	casperArgs, err := c.casperArgs(options)
+	if err != nil {
+		log.Printf("Run() - Test %s - Error: %s", c.Name, err.Error())
+		parser.addError(err)
+		return
+	}
+
+	// The time limit is enforced through the context rather than the pipe state, since
+	// the pipe package would only kill the casperjs process, and not the PhantomJS ones
+	runCtx := ctx
+	timeout := c.timeoutFor(options)
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	cPipe := pipe.Line(
+		pipe.ChDir(c.workDir),
+		pipe.TaskFunc(func(s *pipe.State) error {
+			return runCasperTask(runCtx, c, casperArgs, s)
+		}),
+		pipe.Filter(func(line []byte) bool {
+			printOutputLine(c, streamStdout, string(line))
+			parser.parseStreamLine(streamStdout, string(line), time.Now())
+			return true
+		}),
+	)
+
+	// The stdout lines go through the filter above, and the stderr ones through a line writer
+	stdErr := &lineWriter{handle: func(line string) {
+		printOutputLine(c, streamStderr, line)
+		parser.parseStreamLine(streamStderr, line, time.Now())
+	}}
+	defer stdErr.Flush()
+
+	state := pipe.NewState(nil, stdErr)
+	err = cPipe(state)
+	if err == nil {
+		err = state.RunTasks()
+	}
+	switch {
+	case ctx.Err() != nil:
+		parser.markCancelled(ctx.Err())
+	case err != nil && runCtx.Err() != nil:
+		log.Printf("Run() - Test %s - Timed out after %s", c.Name, timeout)
+		parser.markTimedOut(timeout)
+	case err != nil:
+		log.Printf("Run() - Test %s - pipe.Run() Error: %s", c.Name, err.Error())
+		if !parser.summaryReportsFailures() {
+			parser.addError(err)
+		}
+	}
+	return
+}
+
+// runCasperTask runs casperjs as a pipe task, wired to the streams of the pipe state.
+// It is started in its own process group, which gets killed once runCtx is done.
+func runCasperTask(runCtx context.Context, c *CasperTest, casperArgs []string, s *pipe.State) error {
+
+	casperCmd := exec.CommandContext(runCtx, "casperjs", casperArgs...)
+	casperCmd.Dir = s.Dir
+	casperCmd.Env = s.Env
+	casperCmd.Stdin = s.Stdin
+	casperCmd.Stdout = s.Stdout
+	casperCmd.Stderr = s.Stderr
+	casperCmd.WaitDelay = outputGracePeriod
+	setProcessGroup(casperCmd)
+	casperCmd.Cancel = func() error {
+		log.Printf("Run() - Test %s - Stopping CasperJS: %s", c.Name, runCtx.Err())
+		return killProcessGroup(casperCmd)
+	}
+
+	err := casperCmd.Run()
+	if errors.Is(err, exec.ErrWaitDelay) {
+		log.Printf("Run() - Test %s - CasperJS output still open after it exited, closed it", c.Name)
+		return nil
+	}
+	return err
+}
+
+// ReplayRunner does not launch anything: it replays the CasperJS output recorded for
+// every test, so that the orchestration, retries and reports can be exercised without
+// casperjs installed
+type ReplayRunner struct {
+	// Dir holds the recorded outputs: either one <test key>.log file per test, falling
+	// back to <test id>.log, or the <test key>/casperjs.log files of an artifacts run
+	// directory. Retries replay <test key>.attempt-<n>.log or <test key>.attempt-<n>/casperjs.log
+	// when recorded, and the outputs of the first attempt otherwise. Plain output and the
+	// timestamped casperjs.log format are both accepted.
+	Dir string
+}
+
+// Run feeds the recorded output of the current attempt of the test to the output parser,
+// line by line, until the output ends or ctx is done. A recorded timeout is restored as such.
+func (r *ReplayRunner) Run(ctx context.Context, c *CasperTest, options *RunOptions) *TestResult {
+
+	log.Println("RunViaReplay - About to replay test: ", c.Name)
+	parser := newOutputParser(time.Now())
+
+	candidates := make([]string, 0, 5)
+	if c.attempt > 1 {
+		candidates = append(candidates,
+			filepath.Join(r.Dir, c.attemptDirName(c.attempt)+".log"),
+			filepath.Join(r.Dir, c.attemptDirName(c.attempt), outputLogName))
+	}
+	candidates = append(candidates,
+		filepath.Join(r.Dir, c.dirName()+".log"),
+		filepath.Join(r.Dir, (&CasperTest{Id: c.Id}).dirName()+".log"),
+		filepath.Join(r.Dir, c.dirName(), outputLogName))
+
+	recordedPath := candidates[0]
+	for _, candidate := range candidates {
+		if _, err := os.Stat(candidate); err == nil {
+			recordedPath = candidate
+			break
+		}
+	}
+
+	recorded, err := os.Open(recordedPath)
+	if err != nil {
+		log.Printf("Run() - Test %s - Error reading the recorded output: %s", c.Name, err.Error())
+		parser.addError(err)
+		return parser.finish(time.Now())
+	}
+	defer recorded.Close()
+
+	err = readLines(recorded, func(line string) {
+		if ctx.Err() != nil {
+			return
+		}
+		stream, text := parseOutputLogLine(line)
+		if stream == streamRunner {
+			if strings.HasPrefix(text, timeoutMarker) {
+				limit, _ := time.ParseDuration(strings.TrimPrefix(text, timeoutMarker))
+				parser.markTimedOut(limit)
+			}
+			return
+		}
+		printOutputLine(c, stream, text)
+		parser.parseStreamLine(stream, text, time.Now())
+	})
+	if err != nil {
+		parser.addError(err)
+	}
+	if ctx.Err() != nil {
+		parser.markCancelled(ctx.Err())
+	}
+	return parser.finish(time.Now())
+}
+
+// outputGracePeriod is how long the output of CasperJS may stay open once it exited or
+// was stopped. Should a process that left its process group keep the output open, the
+// output gets closed after that period, so that reading it never outlives the test for long.
+const outputGracePeriod = 5 * time.Second
@@ -0,0 +1,255 @@
+package casperjs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Variable names that must be present in the CasperJS scripts in order to get parsed
+var ManifestVariables = [...]string{"MANIFEST_SCRIPT_ID", "MANIFEST_SCRIPT_NAME",
+	"MANIFEST_SCRIPT_DESC"}
+
+// Variable names that may be present in the CasperJS scripts, but are not required
+var OptionalManifestVariables = [...]string{"MANIFEST_SCRIPT_TIMEOUT", "MANIFEST_SCRIPT_TAGS",
+	"MANIFEST_SCRIPT_RETRIES", "MANIFEST_SCRIPT_PARAMS", "MANIFEST_SCRIPT_DEPENDS"}
+
+// RunOptions holds the settings that apply to a whole run of Casper tests
+type RunOptions struct {
+	// Parallelism is the number of tests allowed to run concurrently
+	Parallelism int
+	// Timeout is the default time limit of a test; zero means no limit
+	Timeout time.Duration
+	// Retries is the default number of times a failed test is run again
+	Retries int
+	// Params are passed to every test as casperjs --name=value options
+	Params map[string]string
+	// ArtifactsDir, when set, is the directory of the run under which every test
+	// gets its own working directory, collecting screenshots and other files
+	ArtifactsDir string
+	// Visual configures the comparison of the collected screenshots against baselines
+	Visual *VisualOptions
+	// Runner launches the tests, a StandardLibRunner being used when it is nil
+	Runner Runner
+}
+
+// orDefault returns the options, or the default ones when they are nil
+func (o *RunOptions) orDefault() *RunOptions {
+
+	if o == nil {
+		return &RunOptions{}
+	}
+	return o
+}
+
+// CasperTest holds essential information about a CasperJS test script
+type CasperTest struct {
+	Id          string
+	FilePath    string
+	Name        string
+	Description string
+
+	// Timeout overrides RunOptions.Timeout when set via MANIFEST_SCRIPT_TIMEOUT
+	Timeout time.Duration
+	// Tags are set via MANIFEST_SCRIPT_TAGS, and allow selecting subsets of tests
+	Tags []string
+	// Retries overrides RunOptions.Retries when set via MANIFEST_SCRIPT_RETRIES
+	Retries *int
+	// Params are the runtime parameters declared via MANIFEST_SCRIPT_PARAMS
+	Params []*ParamSpec
+	// Depends lists the ids of the tests that must pass before this one runs,
+	// as set via MANIFEST_SCRIPT_DEPENDS
+	Depends []string
+	// Cell is the matrix cell the test runs in, or nil when no matrix is used
+	Cell *MatrixCell
+
+	// attempt is the number of the current attempt, and workDir the artifacts
+	// directory it runs in, if any
+	attempt int
+	workDir string
+
+	// Result is populated from the CasperJS output once the test has run.
+	// When the test was retried, it holds the outcome of the last attempt.
+	Result *TestResult
+	// Attempts holds the results of every run of the test, in order
+	Attempts []*TestResult
+}
+
+// Key identifies the test within a run: its id, followed by its matrix cell if any
+func (c *CasperTest) Key() string {
+
+	if c.Cell == nil {
+		return c.Id
+	}
+	return fmt.Sprintf("%s[%s]", c.Id, c.Cell.Key)
+}
+
+// SetPropertyByIndex determines which of the fields to set for the CasperTest instance,
+// based on the  manifestVarIndex and the ManifestVariables array
+func (c *CasperTest) SetPropertyByIndex(manifestVarIndex int, value string) {
+
+	switch manifestVarIndex {
+	case 0:
+		c.Id = value
+	case 1:
+		c.Name = value
+	case 2:
+		c.Description = value
+	}
+
+}
+
+// SetManifestVariable sets the field matching any of the required or optional manifest variables
+func (c *CasperTest) SetManifestVariable(manifestVar string, value string) error {
+
+	for i, curManifestVar := range ManifestVariables {
+		if manifestVar == curManifestVar {
+			c.SetPropertyByIndex(i, value)
+			return nil
+		}
+	}
+	return c.SetOptionalProperty(manifestVar, value)
+}
+
+// SetOptionalProperty sets the field matching one of the OptionalManifestVariables.
+// An error is returned if the value cannot be converted to the field type.
+func (c *CasperTest) SetOptionalProperty(manifestVar string, value string) error {
+
+	switch manifestVar {
+	case "MANIFEST_SCRIPT_TIMEOUT":
+		timeout, err := parseManifestDuration(value)
+		if err != nil {
+			return fmt.Errorf("%s: %s", manifestVar, err)
+		}
+		c.Timeout = timeout
+	case "MANIFEST_SCRIPT_TAGS":
+		c.Tags = SplitList(value)
+	case "MANIFEST_SCRIPT_RETRIES":
+		retries, err := strconv.Atoi(value)
+		if err != nil || retries < 0 {
+			return fmt.Errorf("%s: %q is not a non-negative integer", manifestVar, value)
+		}
+		c.Retries = &retries
+	case "MANIFEST_SCRIPT_PARAMS":
+		params, err := parseParamSpecs(value)
+		if err != nil {
+			return fmt.Errorf("%s: %s", manifestVar, err)
+		}
+		c.Params = params
+	case "MANIFEST_SCRIPT_DEPENDS":
+		c.Depends = SplitList(value)
+	}
+
+	return nil
+}
+
+// HasTag tells whether the test carries the given tag, regardless of case
+func (c *CasperTest) HasTag(tag string) bool {
+
+	for _, t := range c.Tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// SplitList splits a comma and/or whitespace separated list, dropping empty items
+func SplitList(value string) []string {
+
+	return strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	})
+}
+
+// timeoutFor returns the time limit that applies to the test under the given options
+func (c *CasperTest) timeoutFor(options *RunOptions) time.Duration {
+
+	if c.Timeout > 0 {
+		return c.Timeout
+	}
+	return options.Timeout
+}
+
+// retriesFor returns the number of retries that applies to the test under the given options
+func (c *CasperTest) retriesFor(options *RunOptions) int {
+
+	if c.Retries != nil {
+		return *c.Retries
+	}
+	return options.Retries
+}
+
+// Run launches the test, and runs it again while it fails or times out, up to
+// the number of retries allowed. Every attempt is kept in c.Attempts. A test that
+// passes after failing is marked as flaky, so that the flakiness stays visible.
+// Once ctx is done, the running attempt is stopped and no further attempt is made.
+// Nil options stand for the default ones.
+func (c *CasperTest) Run(ctx context.Context, options *RunOptions) {
+
+	options = options.orDefault()
+	c.Attempts = nil
+	retries := c.retriesFor(options)
+
+	for attempt := 1; ; attempt++ {
+
+		c.attempt = attempt
+		c.workDir = ""
+		if options.ArtifactsDir != "" {
+			dir, err := c.prepareArtifactDir(options, attempt)
+			if err != nil {
+				log.Printf("Run() - Test %s - Error creating the artifacts directory: %s", c.Name, err.Error())
+			}
+			c.workDir = dir
+		}
+
+		c.Result = options.runner().Run(ctx, c, options)
+
+		c.Result.Attempt = attempt
+		c.Attempts = append(c.Attempts, c.Result)
+
+		if c.workDir != "" {
+			c.Result.ArtifactDir = c.workDir
+			if err := writeOutputLog(filepath.Join(c.workDir, outputLogName), c.Result.Lines); err != nil {
+				log.Printf("Run() - Test %s - Error writing the output log: %s", c.Name, err.Error())
+			}
+			artifacts, err := indexArtifacts(c.workDir)
+			if err != nil {
+				log.Printf("Run() - Test %s - Error indexing artifacts: %s", c.Name, err.Error())
+			}
+			c.Result.Artifacts = artifacts
+
+			if options.Visual != nil && options.Visual.Enabled {
+				c.compareScreenshots(c.Result, options.Visual)
+			}
+		}
+
+		if (c.Result.Status != StatusFailed && c.Result.Status != StatusTimedOut) || attempt > retries ||
+			ctx.Err() != nil {
+			break
+		}
+		log.Printf("Run() - Test %s - Attempt %d ended as %s, retrying (%d of %d)",
+			c.Name, attempt, c.Result.Status, attempt, retries)
+	}
+
+	// The flaky status goes on a copy, so that the last attempt keeps its own passed status
+	if c.Result.Status == StatusPassed && len(c.Attempts) > 1 {
+		flaky := *c.Result
+		flaky.Status = StatusFlaky
+		c.Result = &flaky
+	}
+}
+
+// parseManifestDuration accepts either a plain number of seconds, or a Go duration string such as "90s"
+func parseManifestDuration(value string) (time.Duration, error) {
+
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		return time.Duration(seconds * float64(time.Second)), nil
+	}
+	return time.ParseDuration(value)
+}
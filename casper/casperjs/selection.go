@@ -0,0 +1,122 @@
+package casperjs
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Selection holds the criteria deciding which of the discovered Casper tests get run
+type Selection struct {
+	// Tags, when not empty, only selects the tests carrying at least one of them
+	Tags []string
+	// ExcludeTags leaves out the tests carrying any of them
+	ExcludeTags []string
+	// RunPattern, when set, only selects the tests whose Id or Name matches it
+	RunPattern *regexp.Regexp
+	// Include, when not empty, only selects the files matching at least one of the globs
+	Include []string
+	// Exclude leaves out the files and folders matching any of the globs
+	Exclude []string
+}
+
+// SkipReason returns why the given test is left out by the selection,
+// or an empty string if the test should run
+func (s *Selection) SkipReason(t *CasperTest) string {
+
+	if s == nil {
+		return ""
+	}
+
+	if s.RunPattern != nil && !s.RunPattern.MatchString(t.Id) && !s.RunPattern.MatchString(t.Name) {
+		return fmt.Sprintf("neither id nor name matches -run %q", s.RunPattern.String())
+	}
+
+	for _, tag := range s.ExcludeTags {
+		if t.HasTag(tag) {
+			return fmt.Sprintf("excluded by tag %q", tag)
+		}
+	}
+
+	if len(s.Tags) > 0 {
+		found := false
+		for _, tag := range s.Tags {
+			if t.HasTag(tag) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Sprintf("none of the tags %s is present", strings.Join(s.Tags, ","))
+		}
+	}
+
+	return ""
+}
+
+// skipPathReason returns why the file or folder at relPath (relative to the scripts
+// folder) is left out by the Include and Exclude globs, or an empty string if it is kept.
+// Folders are only subject to the Exclude globs.
+func (s *Selection) skipPathReason(relPath string, isDir bool) string {
+
+	if s == nil {
+		return ""
+	}
+
+	for _, glob := range s.Exclude {
+		if matchesGlob(glob, relPath) {
+			return fmt.Sprintf("matches -exclude %q", glob)
+		}
+	}
+
+	if isDir || len(s.Include) == 0 {
+		return ""
+	}
+
+	for _, glob := range s.Include {
+		if matchesGlob(glob, relPath) {
+			return ""
+		}
+	}
+	return fmt.Sprintf("matches none of the -include globs %s", strings.Join(s.Include, ","))
+}
+
+// matchesGlob matches the glob against the whole relative path, and, for globs
+// without a path separator, against the base name as well
+func matchesGlob(glob string, relPath string) bool {
+
+	relPath = filepath.ToSlash(relPath)
+	if matched, _ := filepath.Match(glob, relPath); matched {
+		return true
+	}
+	if !strings.Contains(glob, "/") {
+		matched, _ := filepath.Match(glob, filepath.Base(relPath))
+		return matched
+	}
+	return false
+}
+
+// KeepsPath tells whether the script at pathToFile, and every folder leading to it
+// from scriptFolder, are kept by the path globs
+func (s *Selection) KeepsPath(scriptFolder string, pathToFile string) bool {
+
+	if s == nil {
+		return true
+	}
+
+	relPath, err := filepath.Rel(scriptFolder, pathToFile)
+	if err != nil {
+		return true
+	}
+
+	if s.skipPathReason(relPath, false) != "" {
+		return false
+	}
+	for dir := filepath.Dir(relPath); dir != "." && dir != string(filepath.Separator); dir = filepath.Dir(dir) {
+		if s.skipPathReason(dir, true) != "" {
+			return false
+		}
+	}
+	return true
+}
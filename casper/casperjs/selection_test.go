@@ -0,0 +1,96 @@
+package casperjs
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestSelectionSkipReason(t *testing.T) {
+
+	checkout := &CasperTest{Id: "checkout", Name: "Checkout flow", Tags: []string{"smoke", "Payments"}}
+	search := &CasperTest{Id: "search", Name: "Search page", Tags: []string{"slow"}}
+
+	cases := []struct {
+		name      string
+		selection *Selection
+		wantRun   []string
+	}{
+		{"nil selection", nil, []string{"checkout", "search"}},
+		{"tag", &Selection{Tags: []string{"smoke"}}, []string{"checkout"}},
+		{"tag regardless of case", &Selection{Tags: []string{"payments"}}, []string{"checkout"}},
+		{"any of the tags", &Selection{Tags: []string{"smoke", "slow"}}, []string{"checkout", "search"}},
+		{"excluded tag", &Selection{ExcludeTags: []string{"slow"}}, []string{"checkout"}},
+		{"exclusion wins", &Selection{Tags: []string{"smoke"}, ExcludeTags: []string{"payments"}}, nil},
+		{"run pattern on id", &Selection{RunPattern: regexp.MustCompile("^check")}, []string{"checkout"}},
+		{"run pattern on name", &Selection{RunPattern: regexp.MustCompile("Search")}, []string{"search"}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			run := make([]string, 0)
+			for _, c := range []*CasperTest{checkout, search} {
+				if tc.selection.SkipReason(c) == "" {
+					run = append(run, c.Id)
+				}
+			}
+			if len(run) != len(tc.wantRun) {
+				t.Fatalf("got %q selected, want %q", run, tc.wantRun)
+			}
+			for i := range run {
+				if run[i] != tc.wantRun[i] {
+					t.Errorf("got %q selected, want %q", run, tc.wantRun)
+				}
+			}
+		})
+	}
+}
+
+func TestMatchesGlob(t *testing.T) {
+
+	cases := []struct {
+		glob    string
+		relPath string
+		want    bool
+	}{
+		{"checkout.js", "checkout.js", true},
+		{"checkout.js", "shop/checkout.js", true},
+		{"*.js", "shop/checkout.js", true},
+		{"shop/*.js", "shop/checkout.js", true},
+		{"shop/*.js", "shop/cart/checkout.js", false},
+		{"shop/*.js", "other/checkout.js", false},
+		{"legacy", "legacy", true},
+		{"check*", "shop/search.js", false},
+	}
+
+	for _, tc := range cases {
+		if got := matchesGlob(tc.glob, tc.relPath); got != tc.want {
+			t.Errorf("matchesGlob(%q, %q) = %t, want %t", tc.glob, tc.relPath, got, tc.want)
+		}
+	}
+}
+
+func TestSelectionSkipPathReason(t *testing.T) {
+
+	selection := &Selection{Include: []string{"shop/*.js"}, Exclude: []string{"legacy"}}
+
+	cases := []struct {
+		relPath string
+		isDir   bool
+		want    bool
+	}{
+		{"shop/checkout.js", false, true},
+		{"search.js", false, false},
+		{"legacy", true, false},
+		{"shop/legacy", true, false},
+		{"shop", true, true},
+	}
+
+	for _, tc := range cases {
+		if got := selection.skipPathReason(tc.relPath, tc.isDir) == ""; got != tc.want {
+			t.Errorf("%s kept: %t, want %t", tc.relPath, got, tc.want)
+		}
+	}
+	if reason := (*Selection)(nil).skipPathReason("search.js", false); reason != "" {
+		t.Errorf("got %q from a nil selection, want every path kept", reason)
+	}
+}
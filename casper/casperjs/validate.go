@@ -0,0 +1,327 @@
+package casperjs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/robertkrimen/otto/ast"
+	"github.com/robertkrimen/otto/file"
+	"github.com/robertkrimen/otto/parser"
+)
+
+// ManifestProblem describes an issue found while validating a Casper script.
+// Line and Column are zero when the problem concerns the file as a whole.
+type ManifestProblem struct {
+	File    string
+	Line    int
+	Column  int
+	Message string
+}
+
+// String formats the problem as "file:line:column: message"
+func (p *ManifestProblem) String() string {
+
+	if p.Line == 0 {
+		return fmt.Sprintf("%s: %s", p.File, p.Message)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", p.File, p.Line, p.Column, p.Message)
+}
+
+// ScriptValidation is the outcome of validating a single script
+type ScriptValidation struct {
+	Path string
+	// HasManifest is false for files that do not mention any of the manifest
+	// variables, which are not Casper tests at all
+	HasManifest bool
+	Id          string
+	// Depends holds the ids listed in MANIFEST_SCRIPT_DEPENDS
+	Depends  []string
+	Problems []*ManifestProblem
+
+	idPosition      *file.Position
+	dependsPosition *file.Position
+}
+
+// problemAt records a problem found at the given position, which may be nil
+func (v *ScriptValidation) problemAt(position *file.Position, format string, args ...interface{}) {
+
+	problem := &ManifestProblem{File: v.Path, Message: fmt.Sprintf(format, args...)}
+	if position != nil {
+		problem.Line = position.Line
+		problem.Column = position.Column
+	}
+	v.Problems = append(v.Problems, problem)
+}
+
+// ValidateFolder validates every script under scriptFolder kept by the path globs
+// of the selection, and reports the MANIFEST_SCRIPT_IDs used by more than one script,
+// as well as dependencies on unknown ids and dependency cycles. The ids of the scripts
+// left out by the path globs still count as known.
+func ValidateFolder(ctx context.Context, scriptFolder string, selection *Selection) []*ScriptValidation {
+
+	validations := make([]*ScriptValidation, 0)
+	knownIds := make(map[string]bool)
+	WalkScripts(ctx, scriptFolder, nil, func(pathToFile string, info os.FileInfo) {
+		v := ValidateScript(pathToFile)
+		if v.Id != "" {
+			knownIds[v.Id] = true
+		}
+		if selection.KeepsPath(scriptFolder, pathToFile) {
+			validations = append(validations, v)
+		}
+	})
+
+	byId := make(map[string][]*ScriptValidation)
+	ids := make([]string, 0)
+	for _, v := range validations {
+		if v.Id == "" {
+			continue
+		}
+		if _, seen := byId[v.Id]; !seen {
+			ids = append(ids, v.Id)
+		}
+		byId[v.Id] = append(byId[v.Id], v)
+	}
+
+	sort.Strings(ids)
+	for _, id := range ids {
+		duplicates := byId[id]
+		if len(duplicates) < 2 {
+			continue
+		}
+		for _, v := range duplicates {
+			for _, other := range duplicates {
+				if other != v {
+					v.problemAt(v.idPosition, "duplicate MANIFEST_SCRIPT_ID %q, also used by %s", id, other.Path)
+				}
+			}
+		}
+	}
+
+	checkDependencies(validations, byId, knownIds)
+	return validations
+}
+
+// checkDependencies reports the MANIFEST_SCRIPT_DEPENDS entries that do not match any
+// of the knownIds, and the validated scripts whose dependencies form a cycle
+func checkDependencies(validations []*ScriptValidation, byId map[string][]*ScriptValidation, knownIds map[string]bool) {
+
+	ids := make([]string, 0, len(byId))
+	edges := make(map[string][]string)
+	for _, v := range validations {
+		for _, dependency := range v.Depends {
+			if !knownIds[dependency] {
+				v.problemAt(v.dependsPosition, "MANIFEST_SCRIPT_DEPENDS refers to the unknown id %q", dependency)
+				continue
+			}
+			// Scripts left out by the path globs do not run, so they cannot close a cycle
+			if _, selected := byId[dependency]; selected {
+				edges[v.Id] = append(edges[v.Id], dependency)
+			}
+		}
+	}
+	for id := range byId {
+		ids = append(ids, id)
+	}
+
+	// Report every cycle once, on each of the scripts it goes through
+	for {
+		cycle := findDependencyCycle(ids, edges)
+		if cycle == nil {
+			return
+		}
+		for _, id := range cycle[:len(cycle)-1] {
+			for _, v := range byId[id] {
+				v.problemAt(v.dependsPosition, "dependency cycle: %s", strings.Join(cycle, " -> "))
+			}
+		}
+		// Break the cycle before looking for the next one
+		delete(edges, cycle[0])
+	}
+}
+
+// CollectProblems returns the problems of all the validations, in order
+func CollectProblems(validations []*ScriptValidation) []*ManifestProblem {
+
+	problems := make([]*ManifestProblem, 0)
+	for _, v := range validations {
+		problems = append(problems, v.Problems...)
+	}
+	return problems
+}
+
+// ValidateScript checks the Javascript syntax and the manifest of the script at
+// pathToFile, without running it
+func ValidateScript(pathToFile string) *ScriptValidation {
+
+	v := &ScriptValidation{Path: pathToFile, HasManifest: true}
+
+	contents, err := ioutil.ReadFile(pathToFile)
+	if err != nil {
+		v.problemAt(nil, "unreadable file: %s", err)
+		return v
+	}
+
+	v.HasManifest = objectManifestRegex.Match(contents)
+	for _, manifestVar := range ManifestVariables {
+		if bytes.Contains(contents, []byte(manifestVar)) {
+			v.HasManifest = true
+		}
+	}
+	if !v.HasManifest {
+		return v
+	}
+
+	program, err := parser.ParseFile(nil, pathToFile, contents, 0)
+	if err != nil {
+		if errList, ok := err.(parser.ErrorList); ok {
+			for _, parseErr := range errList {
+				position := parseErr.Position
+				v.problemAt(&position, "syntax error: %s", parseErr.Message)
+			}
+		} else {
+			v.problemAt(nil, "syntax error: %s", err)
+		}
+		return v
+	}
+
+	casperTest := &CasperTest{}
+	found := make(map[string]bool)
+	evaluator := newManifestEvaluator(program)
+
+	// checkValue validates a single manifest value, whether it comes from a
+	// MANIFEST_SCRIPT_* variable or from a property of the MANIFEST object
+	checkValue := func(manifestVar string, label string, expr ast.Expression, position *file.Position) {
+
+		if found[manifestVar] {
+			v.problemAt(position, "%s is defined more than once", manifestVar)
+		}
+		found[manifestVar] = true
+
+		variableValue, err := evaluator.expressionValue(expr)
+		switch {
+		case err != nil:
+			v.problemAt(position, "%s cannot be resolved: %s", label, err)
+		case variableValue == "" && isRequiredManifestVariable(manifestVar):
+			v.problemAt(position, "%s is empty", label)
+		default:
+			if err := casperTest.SetManifestVariable(manifestVar, variableValue); err != nil {
+				v.problemAt(position, "%s", err)
+			}
+			switch manifestVar {
+			case ManifestVariables[0]:
+				v.Id, v.idPosition = variableValue, position
+			case "MANIFEST_SCRIPT_DEPENDS":
+				v.Depends, v.dependsPosition = casperTest.Depends, position
+			}
+		}
+	}
+
+	for _, declaration := range program.DeclarationList {
+
+		varDecl, ok := declaration.(*ast.VariableDeclaration)
+		if !ok {
+			continue
+		}
+
+		for _, varExpr := range varDecl.List {
+
+			position := program.File.Position(varExpr.Idx)
+
+			if varExpr.Name == ObjectManifestVariable {
+				properties, err := objectManifestProperties(varExpr.Initializer)
+				if err != nil {
+					v.problemAt(position, "%s", err)
+				}
+				for _, property := range properties {
+					checkValue(property.ManifestVar, ObjectManifestVariable+"."+property.Key,
+						property.Value, program.File.Position(property.Value.Idx0()))
+				}
+				continue
+			}
+
+			if isManifestVariable(varExpr.Name) {
+				checkValue(varExpr.Name, varExpr.Name, varExpr.Initializer, position)
+			}
+		}
+	}
+
+	for _, manifestVar := range ManifestVariables {
+		if !found[manifestVar] {
+			v.problemAt(nil, "missing top-level %s, or the matching key of a %s object", manifestVar, ObjectManifestVariable)
+		}
+	}
+
+	// Manifest variables hoisted into a function scope are invisible to the loader
+	ast.Walk(&nestedManifestVisitor{program: program, validation: v}, program)
+
+	return v
+}
+
+// nestedManifestVisitor reports manifest variables declared inside functions
+type nestedManifestVisitor struct {
+	program    *ast.Program
+	validation *ScriptValidation
+}
+
+func (n *nestedManifestVisitor) Enter(node ast.Node) ast.Visitor {
+
+	function, ok := node.(*ast.FunctionLiteral)
+	if !ok {
+		return n
+	}
+
+	for _, declaration := range function.DeclarationList {
+		varDecl, ok := declaration.(*ast.VariableDeclaration)
+		if !ok {
+			continue
+		}
+		for _, varExpr := range varDecl.List {
+			if isManifestVariable(varExpr.Name) {
+				n.validation.problemAt(n.program.File.Position(varExpr.Idx),
+					"%s must be declared at the top level of the script, not inside a function", varExpr.Name)
+			}
+		}
+	}
+	return n
+}
+
+func (n *nestedManifestVisitor) Exit(node ast.Node) {}
+
+// isRequiredManifestVariable tells whether name is one of the required manifest variables.
+// Optional ones may be empty, e.g. an empty list of tags means none.
+func isRequiredManifestVariable(name string) bool {
+
+	for _, manifestVar := range ManifestVariables {
+		if name == manifestVar {
+			return true
+		}
+	}
+	return false
+}
+
+// isManifestVariable tells whether name is one of the required or optional manifest
+// variables, or the MANIFEST object
+func isManifestVariable(name string) bool {
+
+	if name == ObjectManifestVariable {
+		return true
+	}
+
+	for _, manifestVar := range ManifestVariables {
+		if name == manifestVar {
+			return true
+		}
+	}
+	for _, manifestVar := range OptionalManifestVariables {
+		if name == manifestVar {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,167 @@
+package casperjs
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// validScript declares a complete manifest, along with some optional variables
+const validScript = `var MANIFEST_SCRIPT_ID = "checkout";
+var MANIFEST_SCRIPT_NAME = "Checkout";
+var MANIFEST_SCRIPT_DESC = "Buys a product";
+var MANIFEST_SCRIPT_TAGS = ["smoke", "payments"];
+var MANIFEST_SCRIPT_TIMEOUT = 30;
+casper.test.begin("Checkout", function (test) { test.done(); });
+`
+
+// writeFiles writes every file, given by its path relative to a new temporary
+// directory, and returns that directory
+func writeFiles(t *testing.T, files map[string]string) string {
+
+	dir := t.TempDir()
+	for name, contents := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestValidateScript(t *testing.T) {
+
+	cases := []struct {
+		name         string
+		script       string
+		wantProblems []string
+	}{
+		{"valid", validScript, nil},
+		{"missing variable", `var MANIFEST_SCRIPT_ID = "a";
+var MANIFEST_SCRIPT_NAME = "a";`,
+			[]string{"script.js: missing top-level MANIFEST_SCRIPT_DESC, or the matching key of a MANIFEST object"}},
+		{"empty and non-literal values", `var MANIFEST_SCRIPT_ID = "";
+var MANIFEST_SCRIPT_NAME = getName();
+var MANIFEST_SCRIPT_DESC = "d";`,
+			[]string{"script.js:1:5: MANIFEST_SCRIPT_ID is empty", "script.js:2:5: MANIFEST_SCRIPT_NAME cannot be resolved: " + errNotConstant.Error()}},
+		{"computed values", `var PRODUCT = "Shop";
+var MANIFEST_SCRIPT_ID = PRODUCT.toLowerCase() + "-home";
+var MANIFEST_SCRIPT_NAME = [PRODUCT, "home"].join(" ");
+var MANIFEST_SCRIPT_DESC = "d";`, nil},
+		{"object manifest", `var PRODUCT = "Shop";
+var MANIFEST = {id: "home", name: PRODUCT + " home", desc: "d", tags: ["smoke"], timeout: 30};`, nil},
+		{"invalid object manifest", `var MANIFEST = {id: "home", name: "", desc: "d", owner: "qa"};`,
+			[]string{"script.js:1:5: MANIFEST holds unknown keys: [owner]", "script.js:1:35: MANIFEST.name is empty"}},
+		{"empty optional lists", `var MANIFEST = {id: "a", name: "a", desc: "a", tags: []};
+var MANIFEST_SCRIPT_DEPENDS = [];`, nil},
+		{"invalid optional value", validScript + `var MANIFEST_SCRIPT_RETRIES = "often";`,
+			[]string{`script.js:7:5: MANIFEST_SCRIPT_RETRIES: "often" is not a non-negative integer`}},
+		{"nested declaration", validScript + `function setup() {
+  var MANIFEST_SCRIPT_TAGS = "slow";
+}`,
+			[]string{"script.js:8:7: MANIFEST_SCRIPT_TAGS must be declared at the top level of the script, not inside a function"}},
+		{"syntax error", validScript + "var broken = ;\n",
+			[]string{"script.js:7:14: syntax error: Unexpected token ;", "script.js:8:1: syntax error: Unexpected end of input"}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			dir := writeFiles(t, map[string]string{"script.js": tc.script})
+			v := ValidateScript(filepath.Join(dir, "script.js"))
+
+			got := make([]string, 0, len(v.Problems))
+			for _, problem := range v.Problems {
+				got = append(got, strings.TrimPrefix(problem.String(), dir+string(filepath.Separator)))
+			}
+			if strings.Join(got, "\n") != strings.Join(tc.wantProblems, "\n") {
+				t.Errorf("got problems:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(tc.wantProblems, "\n"))
+			}
+			if !v.HasManifest {
+				t.Error("got a script without manifest")
+			}
+		})
+	}
+}
+
+func TestValidateFolder(t *testing.T) {
+
+	dir := writeFiles(t, map[string]string{
+		"checkout.js":        validScript,
+		"shop/checkout.js":   validScript,
+		"helpers/library.js": "function helper() {}",
+	})
+
+	validations := ValidateFolder(context.Background(), dir, nil)
+	if len(validations) != 3 {
+		t.Fatalf("got %d validations, want 3", len(validations))
+	}
+
+	problems := CollectProblems(validations)
+	if len(problems) != 2 {
+		t.Fatalf("got %d problems, want the duplicate id reported for both scripts", len(problems))
+	}
+	for _, problem := range problems {
+		if problem.Line != 1 || !strings.Contains(problem.Message, `duplicate MANIFEST_SCRIPT_ID "checkout"`) {
+			t.Errorf("got problem %s", problem)
+		}
+	}
+
+	for _, v := range validations {
+		if strings.HasSuffix(v.Path, "library.js") && v.HasManifest {
+			t.Error("got a manifest for a helper script")
+		}
+	}
+}
+
+func TestValidateFolderDependencies(t *testing.T) {
+
+	script := func(id string, depends string) string {
+		return `var MANIFEST = {id: "` + id + `", name: "` + id + `", desc: "` + id + `"};
+var MANIFEST_SCRIPT_DEPENDS = ` + depends + `;`
+	}
+	dir := writeFiles(t, map[string]string{
+		"a.js": script("a", `["b"]`),
+		"b.js": script("b", `["a"]`),
+		"c.js": script("c", `["a", "missing"]`),
+	})
+
+	got := make([]string, 0)
+	for _, problem := range CollectProblems(ValidateFolder(context.Background(), dir, nil)) {
+		got = append(got, strings.TrimPrefix(problem.String(), dir+string(filepath.Separator)))
+	}
+	want := []string{
+		"a.js:2:5: dependency cycle: a -> b -> a",
+		"b.js:2:5: dependency cycle: a -> b -> a",
+		`c.js:2:5: MANIFEST_SCRIPT_DEPENDS refers to the unknown id "missing"`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got problems:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestValidateFolderDependenciesOutsideSelection(t *testing.T) {
+
+	dir := writeFiles(t, map[string]string{
+		"a.js": `var MANIFEST = {id: "a", name: "a", desc: "a"};`,
+		"b.js": `var MANIFEST = {id: "b", name: "b", desc: "b"};
+var MANIFEST_SCRIPT_DEPENDS = ["a", "missing"];`,
+	})
+
+	validations := ValidateFolder(context.Background(), dir, &Selection{Include: []string{"b.js"}})
+	if len(validations) != 1 {
+		t.Fatalf("got %d validations, want only the included script", len(validations))
+	}
+
+	// a is left out by -include, but still exists
+	problems := CollectProblems(validations)
+	if len(problems) != 1 || problems[0].Message != `MANIFEST_SCRIPT_DEPENDS refers to the unknown id "missing"` {
+		t.Errorf("got problems %v, want only the missing id reported", problems)
+	}
+}
@@ -0,0 +1,309 @@
+package casperjs
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// VisualOptions configures the comparison of screenshots against approved baselines
+type VisualOptions struct {
+	// Enabled turns the comparison on
+	Enabled bool
+	// Tolerance is the percentage of differing pixels allowed before the test fails
+	Tolerance float64
+	// ColorTolerance is the per-channel difference (0-255) below which two pixels are considered equal
+	ColorTolerance uint8
+}
+
+// VisualDiff is the outcome of comparing a single screenshot against its baseline
+type VisualDiff struct {
+	Capture  string
+	Baseline string
+	// DiffImage highlights the differing pixels in red, and is empty when the images match
+	DiffImage   string
+	DiffPercent float64
+	// MissingBaseline is true when no screenshot was approved yet under that name
+	MissingBaseline bool
+	Passed          bool
+}
+
+// visualSuite is the suite name of the assertions added by the visual comparison
+const visualSuite = "Visual regression"
+
+// diffImageSuffix marks the highlighted diff images, which are never compared themselves
+const diffImageSuffix = ".diff.png"
+
+// baselineDir returns the folder holding the approved screenshots of the test,
+// stored beside its script, e.g. samples/baselines/bloomberg-home-page
+func (c *CasperTest) baselineDir() string {
+	return filepath.Join(filepath.Dir(c.FilePath), "baselines", c.dirName())
+}
+
+// compareScreenshots compares every PNG screenshot of the result against the baseline
+// with the same name. Each comparison is recorded as an assertion, so that a screenshot
+// differing beyond the tolerance fails the test.
+func (c *CasperTest) compareScreenshots(result *TestResult, options *VisualOptions) {
+
+	for _, artifact := range result.Artifacts {
+
+		if artifact.Kind != "screenshot" || !strings.EqualFold(filepath.Ext(artifact.Path), ".png") ||
+			strings.HasSuffix(artifact.Path, diffImageSuffix) {
+			continue
+		}
+
+		name := filepath.Base(artifact.Path)
+		diff := &VisualDiff{Capture: artifact.Path, Baseline: filepath.Join(c.baselineDir(), name)}
+		assertion := &Assertion{Suite: visualSuite}
+
+		if _, err := os.Stat(diff.Baseline); os.IsNotExist(err) {
+			diff.MissingBaseline = true
+			diff.Passed = true
+			assertion.Status = AssertionSkipped
+			assertion.Message = fmt.Sprintf("%s has no baseline yet, use \"casper approve\" to approve it", name)
+		} else {
+			diffImage := strings.TrimSuffix(artifact.Path, filepath.Ext(artifact.Path)) + diffImageSuffix
+			percent, err := diffPNGFiles(diff.Baseline, diff.Capture, diffImage, options.ColorTolerance)
+			diff.DiffPercent = percent
+			diff.Passed = err == nil && percent <= options.Tolerance
+
+			switch {
+			case err != nil:
+				assertion.Status = AssertionFailed
+				assertion.Message = fmt.Sprintf("%s could not be compared to its baseline: %s", name, err)
+			case diff.Passed:
+				assertion.Status = AssertionPassed
+				assertion.Message = fmt.Sprintf("%s matches its baseline (%.3f%% different, tolerance %.3f%%)",
+					name, percent, options.Tolerance)
+			default:
+				assertion.Status = AssertionFailed
+				assertion.Message = fmt.Sprintf("%s differs from its baseline by %.3f%% (tolerance %.3f%%)",
+					name, percent, options.Tolerance)
+			}
+
+			if percent > 0 && err == nil {
+				diff.DiffImage = diffImage
+				diffArtifact := &Artifact{Path: diffImage, Kind: "diff"}
+				if info, err := os.Stat(diffImage); err == nil {
+					diffArtifact.Size = info.Size()
+				}
+				result.Artifacts = append(result.Artifacts, diffArtifact)
+			}
+		}
+
+		assertion.Details = map[string]string{"capture": diff.Capture, "baseline": diff.Baseline}
+		if diff.DiffImage != "" {
+			assertion.Details["diff"] = diff.DiffImage
+		}
+
+		result.VisualDiffs = append(result.VisualDiffs, diff)
+		result.Assertions = append(result.Assertions, assertion)
+		if assertion.Status == AssertionFailed && result.Status == StatusPassed {
+			result.Status = StatusFailed
+		}
+	}
+}
+
+// diffPNGFiles compares two PNG files pixel by pixel, and returns the percentage of
+// pixels differing by more than colorTolerance on any channel. When they differ, a
+// copy of the capture is written to diffPath with the differing pixels in red, over
+// a faded version of the rest. Images of different sizes are 100% different.
+func diffPNGFiles(baselinePath string, capturePath string, diffPath string, colorTolerance uint8) (float64, error) {
+
+	baseline, err := decodePNGFile(baselinePath)
+	if err != nil {
+		return 0, err
+	}
+	capture, err := decodePNGFile(capturePath)
+	if err != nil {
+		return 0, err
+	}
+
+	bounds := capture.Bounds()
+	if bounds.Dx() != baseline.Bounds().Dx() || bounds.Dy() != baseline.Bounds().Dy() {
+		return 100, fmt.Errorf("size %dx%d differs from the baseline size %dx%d",
+			bounds.Dx(), bounds.Dy(), baseline.Bounds().Dx(), baseline.Bounds().Dy())
+	}
+
+	total := bounds.Dx() * bounds.Dy()
+	if total == 0 {
+		return 0, nil
+	}
+
+	highlighted := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	offset := baseline.Bounds().Min.Sub(bounds.Min)
+	differing := 0
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			capturePixel := capture.At(x, y)
+			if pixelsDiffer(capturePixel, baseline.At(x+offset.X, y+offset.Y), colorTolerance) {
+				differing++
+				highlighted.Set(x-bounds.Min.X, y-bounds.Min.Y, color.RGBA{R: 255, A: 255})
+				continue
+			}
+			gray := color.GrayModel.Convert(capturePixel).(color.Gray)
+			faded := 255 - (255-gray.Y)/4
+			highlighted.Set(x-bounds.Min.X, y-bounds.Min.Y, color.RGBA{R: faded, G: faded, B: faded, A: 255})
+		}
+	}
+
+	percent := float64(differing) * 100 / float64(total)
+	if differing == 0 {
+		return 0, nil
+	}
+
+	file, err := os.Create(diffPath)
+	if err != nil {
+		return percent, err
+	}
+	defer file.Close()
+	return percent, png.Encode(file, highlighted)
+}
+
+// pixelsDiffer tells whether any channel of the two colors differs by more than tolerance
+func pixelsDiffer(a color.Color, b color.Color, tolerance uint8) bool {
+
+	r1, g1, b1, a1 := a.RGBA()
+	r2, g2, b2, a2 := b.RGBA()
+	limit := uint32(tolerance) * 0x101 // RGBA() returns 16-bit channels
+
+	for _, pair := range [][2]uint32{{r1, r2}, {g1, g2}, {b1, b2}, {a1, a2}} {
+		difference := pair[0] - pair[1]
+		if pair[1] > pair[0] {
+			difference = pair[1] - pair[0]
+		}
+		if difference > limit {
+			return true
+		}
+	}
+	return false
+}
+
+// decodePNGFile reads the PNG image at path
+func decodePNGFile(path string) (image.Image, error) {
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return png.Decode(file)
+}
+
+// ApproveScreenshots promotes the PNG screenshots found in a run's artifacts directory
+// to baselines of the matching tests, replacing the previous baselines with the same
+// name. When a test was retried, the screenshots of its last attempt are used.
+// It returns the paths of the baselines written.
+func ApproveScreenshots(runDir string, tests []*CasperTest) ([]string, error) {
+
+	entries, err := ioutil.ReadDir(runDir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Map each test directory to the directory of its last attempt
+	lastAttempts := make(map[string]string)
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		dirName := entry.Name()
+		if i := strings.Index(dirName, ".attempt-"); i >= 0 {
+			dirName = dirName[:i]
+		}
+		if previous, seen := lastAttempts[dirName]; !seen || attemptNumber(entry.Name()) > attemptNumber(previous) {
+			lastAttempts[dirName] = entry.Name()
+		}
+	}
+
+	approved := make([]string, 0)
+	dirNames := make([]string, 0, len(lastAttempts))
+	for dirName := range lastAttempts {
+		dirNames = append(dirNames, dirName)
+	}
+	sort.Strings(dirNames)
+
+	for _, dirName := range dirNames {
+		test := testForArtifactDir(dirName, tests)
+		if test == nil {
+			continue
+		}
+
+		// Matrix cells get their own baselines, since environments and user agents render differently
+		baselineDir := filepath.Join(filepath.Dir(test.FilePath), "baselines", dirName)
+		captures, err := filepath.Glob(filepath.Join(runDir, lastAttempts[dirName], "*.png"))
+		if err != nil {
+			return approved, err
+		}
+
+		for _, capture := range captures {
+			if strings.HasSuffix(capture, diffImageSuffix) {
+				continue
+			}
+			if err := os.MkdirAll(baselineDir, 0755); err != nil {
+				return approved, err
+			}
+			baseline := filepath.Join(baselineDir, filepath.Base(capture))
+			if err := copyFile(capture, baseline); err != nil {
+				return approved, err
+			}
+			approved = append(approved, baseline)
+		}
+	}
+
+	return approved, nil
+}
+
+// testForArtifactDir finds the test an artifacts directory belongs to: the one whose
+// directory name is the longest match, either exactly or followed by a matrix cell
+func testForArtifactDir(dirName string, tests []*CasperTest) *CasperTest {
+
+	var found *CasperTest
+	for _, t := range tests {
+		testDir := t.dirName()
+		if (dirName == testDir || strings.HasPrefix(dirName, testDir+"_")) &&
+			(found == nil || len(testDir) > len(found.dirName())) {
+			found = t
+		}
+	}
+	return found
+}
+
+// attemptNumber extracts n from a "<test>.attempt-<n>" directory name, the first attempt being 1
+func attemptNumber(dirName string) int {
+
+	n := 1
+	if i := strings.Index(dirName, ".attempt-"); i >= 0 {
+		fmt.Sscanf(dirName[i+len(".attempt-"):], "%d", &n)
+	}
+	return n
+}
+
+// copyFile copies the file at source to destination, replacing it if it exists
+func copyFile(source string, destination string) error {
+
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(destination)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,102 @@
+package casperjs
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writePNG writes a 10x10 image of the base color to path, with the given number
+// of pixels, row by row, set to the changed color
+func writePNG(t *testing.T, path string, base color.RGBA, changed color.RGBA, changedPixels int) {
+
+	img := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	for y := 0; y < 10; y++ {
+		for x := 0; x < 10; x++ {
+			img.Set(x, y, base)
+		}
+	}
+	for x := 0; x < changedPixels; x++ {
+		img.Set(x%10, x/10, changed)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if err := png.Encode(file, img); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDiffPNGFiles(t *testing.T) {
+
+	white := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	offWhite := color.RGBA{R: 250, G: 255, B: 255, A: 255}
+	black := color.RGBA{A: 255}
+
+	cases := []struct {
+		name           string
+		changed        color.RGBA
+		changedPixels  int
+		colorTolerance uint8
+		wantPercent    float64
+	}{
+		{"identical", white, 0, 0, 0},
+		{"five pixels", black, 5, 0, 5},
+		{"every pixel", black, 100, 0, 100},
+		{"within color tolerance", offWhite, 50, 5, 0},
+		{"beyond color tolerance", offWhite, 50, 4, 50},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			dir := t.TempDir()
+			baselinePath := filepath.Join(dir, "baseline.png")
+			capturePath := filepath.Join(dir, "capture.png")
+			diffPath := filepath.Join(dir, "diff.png")
+			writePNG(t, baselinePath, white, white, 0)
+			writePNG(t, capturePath, white, tc.changed, tc.changedPixels)
+
+			percent, err := diffPNGFiles(baselinePath, capturePath, diffPath, tc.colorTolerance)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if percent != tc.wantPercent {
+				t.Errorf("got %.2f%% differing pixels, want %.2f%%", percent, tc.wantPercent)
+			}
+
+			_, statErr := os.Stat(diffPath)
+			if wantDiff := tc.wantPercent > 0; wantDiff != (statErr == nil) {
+				t.Errorf("diff image written: %t, want %t", statErr == nil, wantDiff)
+			}
+		})
+	}
+}
+
+func TestDiffPNGFilesSizeMismatch(t *testing.T) {
+
+	dir := t.TempDir()
+	baselinePath := filepath.Join(dir, "baseline.png")
+	writePNG(t, baselinePath, color.RGBA{A: 255}, color.RGBA{A: 255}, 0)
+
+	capturePath := filepath.Join(dir, "capture.png")
+	file, err := os.Create(capturePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(file, image.NewRGBA(image.Rect(0, 0, 5, 5))); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	percent, err := diffPNGFiles(baselinePath, capturePath, filepath.Join(dir, "diff.png"), 0)
+	if err == nil || percent != 100 {
+		t.Errorf("got %.2f%% and error %v, want 100%% and a size error", percent, err)
+	}
+}
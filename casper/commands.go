@@ -0,0 +1,465 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/silviucm/various/casper/casperjs"
+)
+
+// printUsage lists the available commands
+func printUsage() {
+
+	fmt.Fprintln(os.Stderr, `Usage: casper <command> [flags]
+
+Commands:
+  run       discover and run the Casper tests (default when no command is given)
+  list      print the discovered Casper tests
+  validate  check the manifests and Javascript syntax of the scripts, without running them
+  report    render a results file saved by "run -results"
+  history   show the pass rate, duration and recent failures of a test over past runs
+  approve   promote the screenshots of a run to the baselines of the visual comparison
+
+Use "casper <command> -h" for the flags of each command.`)
+}
+
+// discoveryFlags holds the flags shared by the commands that discover Casper tests
+type discoveryFlags struct {
+	folder      *string
+	tags        *string
+	excludeTags *string
+	runPattern  *string
+	include     stringListFlag
+	exclude     stringListFlag
+}
+
+// addDiscoveryFlags registers the test discovery and selection flags on the flag set
+func addDiscoveryFlags(flags *flag.FlagSet) *discoveryFlags {
+
+	d := &discoveryFlags{}
+	// Collect the location of scripts from command line or default to "./samples"
+	d.folder = flags.String("folder", "./samples", "Casper scripts location, defaults to ./samples")
+	d.tags = flags.String("tags", "", "Comma-separated tags; only tests carrying at least one of them are selected")
+	d.excludeTags = flags.String("exclude-tags", "", "Comma-separated tags; tests carrying any of them are skipped")
+	d.runPattern = flags.String("run", "", "Regular expression; only tests whose id or name matches it are selected")
+	flags.Var(&d.include, "include", "Path glob, relative to -folder, of the scripts to consider (repeatable)")
+	flags.Var(&d.exclude, "exclude", "Path glob, relative to -folder, of the scripts and folders to ignore (repeatable)")
+	return d
+}
+
+// selection builds the Selection described by the discovery flags
+func (d *discoveryFlags) selection() (*casperjs.Selection, error) {
+
+	selection := &casperjs.Selection{
+		Tags:        casperjs.SplitList(*d.tags),
+		ExcludeTags: casperjs.SplitList(*d.excludeTags),
+		Include:     d.include,
+		Exclude:     d.exclude,
+	}
+	if *d.runPattern != "" {
+		pattern, err := regexp.Compile(*d.runPattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -run regular expression: %s", err)
+		}
+		selection.RunPattern = pattern
+	}
+	return selection, nil
+}
+
+// runCommand discovers the Casper tests and runs them, returning the process exit code
+func runCommand(args []string) int {
+
+	flags := flag.NewFlagSet("run", flag.ExitOnError)
+	discovery := addDiscoveryFlags(flags)
+	parallelism := flags.Int("parallel", 1, "Number of Casper tests to run concurrently, defaults to 1")
+	testTimeout := flags.Duration("timeout", 0, "Default time limit per Casper test (e.g. 2m), 0 means no limit")
+	retries := flags.Int("retries", 0, "Number of times a failed Casper test is run again before giving up")
+	params := paramsFlag{}
+	flags.Var(params, "param", "Runtime parameter passed to the scripts as name=value, read through casper.cli (repeatable)")
+	paramsFile := flags.String("params-file", "", "Optional file of runtime parameters for an environment, as name=value lines or a .json object")
+	matrix := &matrixFlag{}
+	flags.Var(matrix, "matrix", "Matrix axis as name=value1,value2; every test runs once per combination of the axes (repeatable)")
+	matrixFile := flags.String("matrix-file", "", "Optional JSON file of matrix axes, whose values may set runtime parameters")
+	artifactsRoot := flags.String("artifacts", "artifacts", "Folder under which each run gets a timestamped directory of screenshots, output logs and other files, empty to disable")
+	visualDiff := flags.Bool("visual", true, "Compare the PNG screenshots collected in -artifacts against the approved baselines beside the scripts")
+	visualTolerance := flags.Float64("visual-tolerance", 0.1, "Percentage of differing pixels allowed before a screenshot fails the test")
+	colorTolerance := flags.Uint("visual-color-tolerance", 16, "Per-channel difference (0-255) below which two pixels are considered equal")
+	runnerName := flags.String("runner", "stdlib", "How the tests are launched: "+strings.Join(casperjs.RunnerNames, ", ")+
+		" (replaying the outputs recorded in -replay-dir)")
+	replayDir := flags.String("replay-dir", "", "Folder of recorded CasperJS outputs for -runner replay: one <test id>.log per test, or the artifacts directory of a previous run")
+	allowInvalid := flags.Bool("allow-invalid", false, "Run the valid tests even if some scripts fail validation")
+	junitReportPath := flags.String("junit", "", "Optional path of a JUnit XML report to write after the run")
+	htmlReportPath := flags.String("html", "", "Optional path of a self-contained HTML report to write after the run")
+	resultsPath := flags.String("results", "", "Optional path of a JSON results file, which can be rendered later by the report command")
+	historyDir := flags.String("history", "", "Optional folder where the results of every run are appended, to be queried by the history command")
+	watchMode := flags.Bool("watch", false, "Keep watching -folder, and re-run the Casper tests whose scripts change")
+	watchInterval := flags.Duration("watch-interval", time.Second, "How often -watch polls -folder for changes")
+	flags.Parse(args)
+
+	selection, err := discovery.selection()
+	if err != nil {
+		log.Println(err)
+		return exitRunnerError
+	}
+
+	// From here on, a signal cancels the discovery, or the tests, and the partial results get written
+	ctx, releaseSignals := trapSignals(context.Background())
+	defer releaseSignals()
+
+	// Refuse to run a folder with invalid manifests, as they would silently drop tests
+	if problems := casperjs.CollectProblems(casperjs.ValidateFolder(ctx, *discovery.folder, selection)); len(problems) > 0 {
+		for _, problem := range problems {
+			log.Println("Validation problem: ", problem)
+		}
+		if !*allowInvalid {
+			log.Printf("Refusing to run with %d validation problem(s), use -allow-invalid to run anyway", len(problems))
+			return exitInvalidScripts
+		}
+	}
+
+	runner, err := casperjs.NewRunner(*runnerName, *replayDir)
+	if err != nil {
+		log.Println(err)
+		return exitRunnerError
+	}
+
+	// Parameters given on the command line override the ones from the file
+	runParams := make(map[string]string)
+	if *paramsFile != "" {
+		fileParams, err := casperjs.LoadParamsFile(*paramsFile)
+		if err != nil {
+			log.Println("Error reading the params file: ", err)
+			return exitRunnerError
+		}
+		for name, value := range fileParams {
+			runParams[name] = value
+		}
+	}
+	for name, value := range params {
+		runParams[name] = value
+	}
+
+	// Axes from the file come first, followed by the ones given as flags
+	runMatrix := &casperjs.Matrix{}
+	if *matrixFile != "" {
+		fileMatrix, err := casperjs.LoadMatrixFile(*matrixFile)
+		if err != nil {
+			log.Println("Error reading the matrix file: ", err)
+			return exitRunnerError
+		}
+		runMatrix.Axes = append(runMatrix.Axes, fileMatrix.Axes...)
+	}
+	if matrix.matrix != nil {
+		runMatrix.Axes = append(runMatrix.Axes, matrix.matrix.Axes...)
+	}
+
+	// Traverse and process the files in the folder
+	testsToRun := casperjs.ExpandMatrix(casperjs.Discover(ctx, *discovery.folder, selection), runMatrix)
+	if ctx.Err() != nil {
+		log.Println("Discovery interrupted, not running any Casper test")
+		return exitInterrupted
+	}
+	if len(testsToRun) == 0 && !*watchMode {
+		log.Println("No valid Casper tests found in: ", *discovery.folder)
+		return exitNoTests
+	}
+
+	runOptions := &casperjs.RunOptions{
+		Parallelism: *parallelism,
+		Timeout:     *testTimeout,
+		Retries:     *retries,
+		Params:      runParams,
+		Runner:      runner,
+		Visual: &casperjs.VisualOptions{
+			Enabled:        *visualDiff,
+			Tolerance:      *visualTolerance,
+			ColorTolerance: uint8(*colorTolerance),
+		},
+	}
+
+	record := &casperjs.RunRecord{StartedAt: time.Now(), Tests: testsToRun}
+
+	// Check the parameters up front, rather than having tests fail one by one
+	missingParams := 0
+	for _, t := range testsToRun {
+		if err := t.CheckParams(runOptions); err != nil {
+			log.Printf("Casper test %s: %s", t.Key(), err)
+			missingParams++
+		}
+	}
+	if missingParams > 0 && !*allowInvalid {
+		log.Printf("Refusing to run %d test(s) without their required parameters, use -allow-invalid to run anyway", missingParams)
+		return exitInvalidScripts
+	}
+
+	if *artifactsRoot != "" {
+		artifactsDir, err := casperjs.NewArtifactsRunDir(*artifactsRoot, record.StartedAt)
+		if err != nil {
+			log.Println("Error creating the artifacts directory: ", err)
+			return exitRunnerError
+		}
+		runOptions.ArtifactsDir = artifactsDir
+		log.Println("Collecting artifacts in: ", runOptions.ArtifactsDir)
+	}
+
+	log.Println("----------------------------------------")
+	casperjs.RunTests(ctx, testsToRun, runOptions)
+	record.FinishedAt = time.Now()
+	if ctx.Err() != nil {
+		log.Println("Run interrupted, writing the results of the completed tests")
+	}
+
+	writeReports(record, *junitReportPath, *htmlReportPath)
+	if *resultsPath != "" {
+		if err := casperjs.SaveRunRecord(*resultsPath, record); err != nil {
+			log.Println("Error writing results file: ", err)
+		} else {
+			log.Println("Results written to: ", *resultsPath)
+		}
+	}
+
+	if *historyDir != "" {
+		if err := casperjs.AppendHistory(*historyDir, record); err != nil {
+			log.Println("Error writing history: ", err)
+		} else {
+			log.Println("History appended in: ", *historyDir)
+		}
+	}
+
+	log.Println("----------------------------------------")
+	printSummary(os.Stdout, testsToRun)
+	printMatrixGrid(os.Stdout, testsToRun)
+
+	if *watchMode && ctx.Err() == nil {
+		watchScripts(ctx, *discovery.folder, selection, runMatrix, runOptions, *artifactsRoot, *watchInterval)
+	}
+	if ctx.Err() != nil {
+		return exitInterrupted
+	}
+	return exitCodeFor(testsToRun)
+}
+
+// writeReports writes the optional reports requested for a run
+func writeReports(record *casperjs.RunRecord, junitReportPath string, htmlReportPath string) {
+
+	if junitReportPath != "" {
+		if err := casperjs.WriteJUnitReport(junitReportPath, record.Tests); err != nil {
+			log.Println("Error writing JUnit report: ", err)
+		} else {
+			log.Println("JUnit report written to: ", junitReportPath)
+		}
+	}
+
+	if htmlReportPath != "" {
+		if err := casperjs.WriteHTMLReport(htmlReportPath, record); err != nil {
+			log.Println("Error writing HTML report: ", err)
+		} else {
+			log.Println("HTML report written to: ", htmlReportPath)
+		}
+	}
+}
+
+// listCommand prints the discovered Casper tests as a table or as JSON
+func listCommand(args []string) int {
+
+	flags := flag.NewFlagSet("list", flag.ExitOnError)
+	discovery := addDiscoveryFlags(flags)
+	asJSON := flags.Bool("json", false, "Print the tests as JSON instead of a table")
+	flags.Parse(args)
+
+	selection, err := discovery.selection()
+	if err != nil {
+		log.Println(err)
+		return exitRunnerError
+	}
+
+	tests := casperjs.Discover(context.Background(), *discovery.folder, selection)
+
+	if *asJSON {
+		type listedTest struct {
+			Id          string   `json:"id"`
+			Name        string   `json:"name"`
+			Description string   `json:"description"`
+			Tags        []string `json:"tags"`
+			Path        string   `json:"path"`
+		}
+
+		listed := make([]listedTest, 0, len(tests))
+		for _, t := range tests {
+			listed = append(listed, listedTest{t.Id, t.Name, t.Description, t.Tags, t.FilePath})
+		}
+
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(listed); err != nil {
+			log.Println("Error encoding the test list: ", err)
+			return exitRunnerError
+		}
+	} else {
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tNAME\tDESCRIPTION\tTAGS\tPATH")
+		for _, t := range tests {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", t.Id, t.Name, t.Description, strings.Join(t.Tags, ","), t.FilePath)
+		}
+		tw.Flush()
+	}
+
+	if len(tests) == 0 {
+		return exitNoTests
+	}
+	return exitOK
+}
+
+// validateCommand checks every script under the folder without running anything
+func validateCommand(args []string) int {
+
+	flags := flag.NewFlagSet("validate", flag.ExitOnError)
+	discovery := addDiscoveryFlags(flags)
+	flags.Parse(args)
+
+	selection, err := discovery.selection()
+	if err != nil {
+		log.Println(err)
+		return exitRunnerError
+	}
+
+	valid, invalid := 0, 0
+	for _, v := range casperjs.ValidateFolder(context.Background(), *discovery.folder, selection) {
+
+		switch {
+		case !v.HasManifest:
+			fmt.Printf("SKIPPED  %s (no manifest)\n", v.Path)
+		case len(v.Problems) == 0:
+			valid++
+			fmt.Printf("OK       %s\n", v.Path)
+		default:
+			invalid++
+			fmt.Printf("INVALID  %s\n", v.Path)
+			for _, problem := range v.Problems {
+				fmt.Printf("         %s\n", problem)
+			}
+		}
+	}
+
+	fmt.Printf("%d valid, %d invalid script(s)\n", valid, invalid)
+
+	switch {
+	case invalid > 0:
+		return exitInvalidScripts
+	case valid == 0:
+		return exitNoTests
+	}
+	return exitOK
+}
+
+// reportCommand renders a results file saved by the run command
+func reportCommand(args []string) int {
+
+	flags := flag.NewFlagSet("report", flag.ExitOnError)
+	junitReportPath := flags.String("junit", "", "Optional path of a JUnit XML report to write")
+	htmlReportPath := flags.String("html", "", "Optional path of a self-contained HTML report to write")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: casper report [flags] <results.json>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return exitRunnerError
+	}
+
+	record, err := casperjs.LoadRunRecord(flags.Arg(0))
+	if err != nil {
+		log.Println("Error reading results file: ", err)
+		return exitRunnerError
+	}
+
+	writeReports(record, *junitReportPath, *htmlReportPath)
+
+	fmt.Printf("Run started %s, finished %s\n", record.StartedAt.Format(time.RFC1123), record.FinishedAt.Format(time.RFC1123))
+	printSummary(os.Stdout, record.Tests)
+	printMatrixGrid(os.Stdout, record.Tests)
+	return exitCodeFor(record.Tests)
+}
+
+// approveCommand promotes the screenshots collected in a run's artifacts directory
+// to the baselines of the matching tests
+func approveCommand(args []string) int {
+
+	flags := flag.NewFlagSet("approve", flag.ExitOnError)
+	discovery := addDiscoveryFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: casper approve [flags] <artifacts run directory>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return exitRunnerError
+	}
+
+	selection, err := discovery.selection()
+	if err != nil {
+		log.Println(err)
+		return exitRunnerError
+	}
+
+	tests := casperjs.Discover(context.Background(), *discovery.folder, selection)
+	approved, err := casperjs.ApproveScreenshots(flags.Arg(0), tests)
+	for _, baseline := range approved {
+		fmt.Println("Approved: ", baseline)
+	}
+	if err != nil {
+		log.Println("Error approving screenshots: ", err)
+		return exitRunnerError
+	}
+
+	fmt.Printf("%d screenshot(s) approved\n", len(approved))
+	return exitOK
+}
+
+// historyCommand prints the trend of a test over the runs recorded by "run -history"
+func historyCommand(args []string) int {
+
+	flags := flag.NewFlagSet("history", flag.ExitOnError)
+	historyDir := flags.String("history", "history", "Folder the results of the runs were appended to with \"run -history\"")
+	trendLength := flags.Int("trend", 30, "Number of recent runs shown in the trend column")
+	failureCount := flags.Int("failures", 5, "Number of recent failed runs whose messages are shown")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: casper history [flags] <test id>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return exitRunnerError
+	}
+
+	entries, err := casperjs.LoadHistory(*historyDir, flags.Arg(0))
+	if err != nil {
+		if os.IsNotExist(err) {
+			log.Printf("No history for Casper test %q in %s", flags.Arg(0), *historyDir)
+			return exitNoTests
+		}
+		log.Println("Error reading history: ", err)
+		return exitRunnerError
+	}
+	if len(entries) == 0 {
+		log.Printf("No history for Casper test %q in %s", flags.Arg(0), *historyDir)
+		return exitNoTests
+	}
+
+	printHistory(os.Stdout, entries, *trendLength, *failureCount)
+	return exitOK
+}
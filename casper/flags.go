@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/silviucm/various/casper/casperjs"
+)
+
+// stringListFlag collects the values of a flag that may be repeated,
+// or given as a comma-separated list
+type stringListFlag []string
+
+func (f *stringListFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringListFlag) Set(value string) error {
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*f = append(*f, item)
+		}
+	}
+	return nil
+}
+
+// paramsFlag collects repeated -param name=value flags
+type paramsFlag map[string]string
+
+func (f paramsFlag) String() string {
+
+	pairs := make([]string, 0, len(f))
+	for name, value := range f {
+		pairs = append(pairs, name+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (f paramsFlag) Set(pair string) error {
+
+	name, value, err := casperjs.SplitParam(pair)
+	if err != nil {
+		return err
+	}
+	f[name] = value
+	return nil
+}
+
+// matrixFlag collects repeated -matrix axis=value1,value2 flags
+type matrixFlag struct {
+	matrix *casperjs.Matrix
+}
+
+func (f *matrixFlag) String() string {
+
+	if f.matrix == nil {
+		return ""
+	}
+	axes := make([]string, 0, len(f.matrix.Axes))
+	for _, axis := range f.matrix.Axes {
+		labels := make([]string, 0, len(axis.Values))
+		for _, value := range axis.Values {
+			labels = append(labels, value.Label)
+		}
+		axes = append(axes, axis.Name+"="+strings.Join(labels, ","))
+	}
+	return strings.Join(axes, " ")
+}
+
+func (f *matrixFlag) Set(definition string) error {
+
+	name, list, err := casperjs.SplitParam(definition)
+	if err != nil {
+		return err
+	}
+
+	axis := &casperjs.MatrixAxis{Name: name}
+	for _, label := range strings.Split(list, ",") {
+		if label = strings.TrimSpace(label); label != "" {
+			axis.Values = append(axis.Values, &casperjs.MatrixValue{Label: label})
+		}
+	}
+	if len(axis.Values) == 0 {
+		return fmt.Errorf("matrix axis %q has no values", name)
+	}
+
+	if f.matrix == nil {
+		f.matrix = &casperjs.Matrix{}
+	}
+	f.matrix.Axes = append(f.matrix.Axes, axis)
+	return nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import "testing"
+
+func TestMatrixFlag(t *testing.T) {
+
+	flag := &matrixFlag{}
+	for _, definition := range []string{"env=staging, prod", "ua=desktop"} {
+		if err := flag.Set(definition); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := flag.String(); got != "env=staging,prod ua=desktop" {
+		t.Errorf("got %q", got)
+	}
+	if len(flag.matrix.Cells()) != 2 {
+		t.Errorf("got %d cells, want 2", len(flag.matrix.Cells()))
+	}
+
+	for _, definition := range []string{"env", "env=,"} {
+		if err := flag.Set(definition); err == nil {
+			t.Errorf("%s: got no error", definition)
+		}
+	}
+}
+
+func TestParamsFlag(t *testing.T) {
+
+	flag := paramsFlag{}
+	for _, pair := range []string{"user=qa", "targetUrl=http://shop?a=b"} {
+		if err := flag.Set(pair); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := flag.String(); got != "targetUrl=http://shop?a=b,user=qa" {
+		t.Errorf("got %q", got)
+	}
+	if err := flag.Set("=value"); err == nil {
+		t.Error("got no error for a parameter without name")
+	}
+}
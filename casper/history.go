@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/silviucm/various/casper/casperjs"
+)
+
+// historyStatusSymbols abbreviates the statuses in the trend column of the history
+var historyStatusSymbols = map[casperjs.TestStatus]string{
+	casperjs.StatusPassed:   ".",
+	casperjs.StatusFlaky:    "~",
+	casperjs.StatusFailed:   "F",
+	casperjs.StatusError:    "E",
+	casperjs.StatusTimedOut: "T",
+	casperjs.StatusSkipped:  "S",
+	casperjs.StatusUnknown:  "?",
+}
+
+// printHistory writes, for every key (matrix cell) of the test, the number of runs,
+// the pass rate, the average duration and the trend of the last runs, followed by
+// the most recent failure messages
+func printHistory(w io.Writer, entries []*casperjs.HistoryEntry, trendLength int, failureCount int) {
+
+	byKey := make(map[string][]*casperjs.HistoryEntry)
+	keys := make([]string, 0)
+	for _, entry := range entries {
+		if _, seen := byKey[entry.Key]; !seen {
+			keys = append(keys, entry.Key)
+		}
+		byKey[entry.Key] = append(byKey[entry.Key], entry)
+	}
+	sort.Strings(keys)
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "KEY\tRUNS\tPASS RATE\tFLAKY\tAVG DURATION\tLAST RUN\tTREND (oldest to newest)")
+	for _, key := range keys {
+		keyEntries := byKey[key]
+
+		passed, flaky := 0, 0
+		var total time.Duration
+		for _, entry := range keyEntries {
+			switch entry.Status {
+			case casperjs.StatusPassed:
+				passed++
+			case casperjs.StatusFlaky:
+				passed++
+				flaky++
+			}
+			total += entry.Duration
+		}
+
+		trend := ""
+		for i := len(keyEntries) - trendLength; i < len(keyEntries); i++ {
+			if i >= 0 {
+				trend += historyStatusSymbols[keyEntries[i].Status]
+			}
+		}
+
+		last := keyEntries[len(keyEntries)-1]
+		fmt.Fprintf(tw, "%s\t%d\t%.1f%%\t%d\t%s\t%s (%s)\t%s\n",
+			key, len(keyEntries), float64(passed)*100/float64(len(keyEntries)), flaky,
+			(total / time.Duration(len(keyEntries))).Round(time.Millisecond),
+			last.RunStartedAt.Format("2006-01-02 15:04"), last.Status, trend)
+	}
+	tw.Flush()
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Most recent failures:")
+	shown := 0
+	for i := len(entries) - 1; i >= 0 && shown < failureCount; i-- {
+		entry := entries[i]
+		if len(entry.Failures) == 0 {
+			continue
+		}
+		shown++
+		fmt.Fprintf(w, "  %s  %s  %s\n", entry.RunStartedAt.Format("2006-01-02 15:04:05"), entry.Key, entry.Status)
+		for _, failure := range entry.Failures {
+			fmt.Fprintf(w, "      %s\n", failure)
+		}
+	}
+	if shown == 0 {
+		fmt.Fprintln(w, "  none")
+	}
+}
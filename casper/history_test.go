@@ -0,0 +1,28 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/silviucm/various/casper/casperjs"
+)
+
+func TestPrintHistory(t *testing.T) {
+
+	startedAt := time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)
+	entries := []*casperjs.HistoryEntry{
+		{RunStartedAt: startedAt, Id: "checkout", Key: "checkout", Status: casperjs.StatusPassed, Duration: time.Second},
+		{RunStartedAt: startedAt.Add(time.Hour), Id: "checkout", Key: "checkout", Status: casperjs.StatusFlaky, Duration: 3 * time.Second},
+		{RunStartedAt: startedAt.Add(2 * time.Hour), Id: "checkout", Key: "checkout", Status: casperjs.StatusFailed,
+			Duration: 2 * time.Second, Failures: []string{"link not found"}},
+	}
+
+	output := &strings.Builder{}
+	printHistory(output, entries, 2, 1)
+	for _, want := range []string{"checkout  3     66.7%      1      2s", "2026-01-01 11:00 (failed)  ~F", "link not found"} {
+		if !strings.Contains(output.String(), want) {
+			t.Errorf("history output lacks %q:\n%s", want, output)
+		}
+	}
+}
@@ -3,15 +3,24 @@
 var MANIFEST_SCRIPT_ID = "bloomberg-home-page";
 var MANIFEST_SCRIPT_NAME = "Bloomberg Home Page Test";
 var MANIFEST_SCRIPT_DESC = "Tests navigation from the home page to the stocks page";
+var MANIFEST_SCRIPT_TAGS = ["smoke", "finance"];
+
+// Runtime parameters, passed by the runner via -param name=value or -params-file,
+// along with their defaults
+var MANIFEST_SCRIPT_PARAMS = {
+    targetUrl: "https://www.bloomberg.com",
+    userAgent: "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) " +
+               "Ubuntu Chromium/53.0.2785.143 Chrome/53.0.2785.143 Safari/537.36",
+    pageLoadTimeout: 10000 // 10 seconds
+};
 
 // END: Script Manifest
 
 // BEGIN: Target settings
-var TargetUrl = "https://www.bloomberg.com";
+var TargetUrl = param("targetUrl");
 var MarketLinkSelector = "a[href$='stocks']"; // =$ means href ends with "stocks"
-var UserAgent = "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) " +
-                "Ubuntu Chromium/53.0.2785.143 Chrome/53.0.2785.143 Safari/537.36";
-var DefaultPageLoadTimeout = 10000; // 10 seconds
+var UserAgent = param("userAgent");
+var DefaultPageLoadTimeout = param("pageLoadTimeout");
 // END: Target settings 
 
 // BEGIN: Screen capture settings
@@ -23,6 +32,11 @@ var TargetViewports = [
 ];
 // END: Screen capture settings 
 
+// param returns the runtime parameter passed on the command line, or its declared default
+function param(name) {
+    return casper.cli.has(name) ? casper.cli.get(name) : MANIFEST_SCRIPT_PARAMS[name];
+}
+
 // BEGIN: test suite definition 
 casper.test.begin(MANIFEST_SCRIPT_DESC, TargetViewports.length * 2, function suite(test) {
     
@@ -1,17 +1,27 @@
 // BEGIN: Script Manifest
 
-var MANIFEST_SCRIPT_ID = "cbc-home-page";
-var MANIFEST_SCRIPT_NAME = "CBC.ca Home Page";
-var MANIFEST_SCRIPT_DESC = "Tests navigation from the CBC.ca home page to the sports page";
+var MANIFEST = {
+    id: "cbc-home-page",
+    name: "CBC.ca Home Page",
+    desc: "Tests navigation from the CBC.ca home page to the sports page",
+    tags: ["smoke", "news"],
+    // Runtime parameters, passed by the runner via -param name=value or -params-file,
+    // along with their defaults
+    params: {
+        targetUrl: "http://www.cbc.ca",
+        userAgent: "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) " +
+                   "Ubuntu Chromium/53.0.2785.143 Chrome/53.0.2785.143 Safari/537.36",
+        pageLoadTimeout: 10000 // 10 seconds
+    }
+};
 
 // END: Script Manifest
 
 // BEGIN: Target settings
-var TargetUrl = "http://www.cbc.ca";
+var TargetUrl = param("targetUrl");
 var SportsLinkSelector = "a[href$='sports']"; // =$ means href ends with "sports"
-var UserAgent = "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) " +
-                "Ubuntu Chromium/53.0.2785.143 Chrome/53.0.2785.143 Safari/537.36";
-var DefaultPageLoadTimeout = 10000; // 10 seconds
+var UserAgent = param("userAgent");
+var DefaultPageLoadTimeout = param("pageLoadTimeout");
 // END: Target settings 
 
 // BEGIN: Screen capture settings
@@ -23,8 +33,13 @@ var TargetViewports = [
 ];
 // END: Screen capture settings 
 
+// param returns the runtime parameter passed on the command line, or its declared default
+function param(name) {
+    return casper.cli.has(name) ? casper.cli.get(name) : MANIFEST.params[name];
+}
+
 // BEGIN: test suite definition 
-casper.test.begin(MANIFEST_SCRIPT_DESC, TargetViewports.length * 2, function suite(test) {
+casper.test.begin(MANIFEST.desc, TargetViewports.length * 2, function suite(test) {
     
     // set the user agent to our choice
     casper.userAgent(UserAgent);
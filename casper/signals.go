@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// trapSignals catches SIGINT and SIGTERM for the duration of a run. The returned
+// context is cancelled on the first signal, so that discovery stops, no new tests get
+// scheduled and the running CasperJS process groups get killed, leaving the runner
+// time to write the partial results. A second signal exits immediately. Calling
+// release stops trapping.
+func trapSignals(parent context.Context) (ctx context.Context, release func()) {
+
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	ctx, cancel := context.WithCancel(parent)
+	done := make(chan struct{})
+
+	go func() {
+		select {
+		case received := <-signals:
+			log.Printf("Received %s, stopping the running Casper tests and writing the partial results. "+
+				"Send it again to exit immediately.", received)
+			cancel()
+		case <-done:
+			return
+		}
+
+		select {
+		case received := <-signals:
+			log.Printf("Received %s again, exiting immediately", received)
+			os.Exit(exitInterrupted)
+		case <-done:
+		}
+	}()
+
+	return ctx, func() {
+		signal.Stop(signals)
+		close(done)
+		cancel()
+	}
+}
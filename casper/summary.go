@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/silviucm/various/casper/casperjs"
+)
+
+// Process exit codes, so that pipelines can gate on the outcome of a run
+const (
+	exitOK          = 0
+	exitTestsFailed = 1
+	exitRunnerError = 2
+	exitNoTests     = 3
+	// exitInvalidScripts is returned when scripts fail validation
+	exitInvalidScripts = 4
+	// exitInterrupted is returned when the run was stopped by SIGINT or SIGTERM,
+	// following the shell convention of 128 + SIGINT
+	exitInterrupted = 130
+)
+
+// printSummary writes a table with the pass/fail/error counts of every test to w,
+// followed by the totals for the whole run
+func printSummary(w io.Writer, tests []*casperjs.CasperTest) {
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tSTATUS\tATTEMPTS\tPASSED\tFAILED\tSKIPPED\tERRORS\tDURATION")
+
+	var passed, failed, skipped, errors int
+	for _, t := range tests {
+		r := t.Result
+		if r == nil {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t0\t-\t-\t-\t-\t-\n", t.Key(), t.Name, casperjs.StatusUnknown)
+			continue
+		}
+
+		p, f, s, e := r.Count(casperjs.AssertionPassed), r.Count(casperjs.AssertionFailed), r.Count(casperjs.AssertionSkipped), len(r.Errors)
+		passed, failed, skipped, errors = passed+p, failed+f, skipped+s, errors+e
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%d\t%d\t%d\t%d\t%s\n",
+			t.Key(), t.Name, r.Status, len(t.Attempts), p, f, s, e, r.Duration)
+	}
+
+	fmt.Fprintf(tw, "TOTAL\t%d tests\t\t\t%d\t%d\t%d\t%d\t\n", len(tests), passed, failed, skipped, errors)
+	tw.Flush()
+}
+
+// exitCodeFor determines the process exit code for a completed run. Runner errors
+// take precedence over failed tests, since they mean the results are incomplete.
+// Flaky tests eventually passed, so they do not fail the run. A cancelled test means
+// the run was interrupted, which takes precedence over anything else.
+func exitCodeFor(tests []*casperjs.CasperTest) int {
+
+	if len(tests) == 0 {
+		return exitNoTests
+	}
+
+	for _, t := range tests {
+		if t.Result != nil && t.Result.Status == casperjs.StatusCancelled {
+			return exitInterrupted
+		}
+	}
+
+	code := exitOK
+	for _, t := range tests {
+		if t.Result == nil {
+			return exitRunnerError
+		}
+
+		switch t.Result.Status {
+		case casperjs.StatusError, casperjs.StatusUnknown:
+			return exitRunnerError
+		case casperjs.StatusFailed, casperjs.StatusTimedOut, casperjs.StatusSkipped:
+			code = exitTestsFailed
+		}
+	}
+
+	return code
+}
+
+// printMatrixGrid writes a grid of test statuses to w, with one row per test id
+// and one column per matrix cell. Nothing is written when no matrix was used.
+func printMatrixGrid(w io.Writer, tests []*casperjs.CasperTest) {
+
+	cellKeys := make([]string, 0)
+	seenCells := make(map[string]bool)
+	ids := make([]string, 0)
+	statuses := make(map[string]map[string]casperjs.TestStatus)
+
+	for _, t := range tests {
+		if t.Cell == nil {
+			continue
+		}
+		if !seenCells[t.Cell.Key] {
+			seenCells[t.Cell.Key] = true
+			cellKeys = append(cellKeys, t.Cell.Key)
+		}
+		if _, seen := statuses[t.Id]; !seen {
+			ids = append(ids, t.Id)
+			statuses[t.Id] = make(map[string]casperjs.TestStatus)
+		}
+		status := casperjs.StatusUnknown
+		if t.Result != nil {
+			status = t.Result.Status
+		}
+		statuses[t.Id][t.Cell.Key] = status
+	}
+
+	if len(cellKeys) == 0 {
+		return
+	}
+
+	fmt.Fprintln(w)
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "ID\t%s\n", strings.Join(cellKeys, "\t"))
+	for _, id := range ids {
+		row := make([]string, 0, len(cellKeys))
+		for _, key := range cellKeys {
+			status, ran := statuses[id][key]
+			if !ran {
+				status = "-"
+			}
+			row = append(row, string(status))
+		}
+		fmt.Fprintf(tw, "%s\t%s\n", id, strings.Join(row, "\t"))
+	}
+	tw.Flush()
+}
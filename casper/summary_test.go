@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/silviucm/various/casper/casperjs"
+)
+
+func TestExitCodeFor(t *testing.T) {
+
+	test := func(status casperjs.TestStatus) *casperjs.CasperTest {
+		return &casperjs.CasperTest{Id: string(status), Result: &casperjs.TestResult{Status: status}}
+	}
+
+	cases := []struct {
+		name  string
+		tests []*casperjs.CasperTest
+		want  int
+	}{
+		{"no tests", nil, exitNoTests},
+		{"passed and flaky", []*casperjs.CasperTest{test(casperjs.StatusPassed), test(casperjs.StatusFlaky)}, exitOK},
+		{"failed", []*casperjs.CasperTest{test(casperjs.StatusPassed), test(casperjs.StatusFailed)}, exitTestsFailed},
+		{"skipped", []*casperjs.CasperTest{test(casperjs.StatusSkipped)}, exitTestsFailed},
+		{"error", []*casperjs.CasperTest{test(casperjs.StatusFailed), test(casperjs.StatusError)}, exitRunnerError},
+		{"not run", []*casperjs.CasperTest{test(casperjs.StatusPassed), {Id: "b"}}, exitRunnerError},
+		{"cancelled", []*casperjs.CasperTest{{Id: "b"}, test(casperjs.StatusFailed), test(casperjs.StatusCancelled)}, exitInterrupted},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := exitCodeFor(tc.tests); got != tc.want {
+				t.Errorf("got exit code %d, want %d", got, tc.want)
+			}
+		})
+	}
+}
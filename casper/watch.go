@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/silviucm/various/casper/casperjs"
+)
+
+// watchScripts polls scriptFolder every interval, and runs again every Casper test
+// whose script was created or modified since the previous poll. Polling keeps the
+// watcher portable, and the scripts folder is small enough for it to be cheap.
+// Every re-run covers each cell of the matrix, and collects its artifacts in a new run
+// directory under artifactsRoot, if set. It only returns once ctx is done.
+func watchScripts(ctx context.Context, scriptFolder string, selection *casperjs.Selection, matrix *casperjs.Matrix,
+	options *casperjs.RunOptions, artifactsRoot string, interval time.Duration) {
+
+	known := scanScripts(ctx, scriptFolder, selection)
+	log.Printf("Watching %s for changes every %s, press Ctrl-C to stop", scriptFolder, interval)
+
+	for {
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return
+		}
+
+		current := scanScripts(ctx, scriptFolder, selection)
+
+		changed := make([]string, 0)
+		for pathToFile, modTime := range current {
+			if previous, seen := known[pathToFile]; !seen || !previous.Equal(modTime) {
+				changed = append(changed, pathToFile)
+			}
+		}
+		known = current
+
+		sort.Strings(changed)
+		for _, pathToFile := range changed {
+			if ctx.Err() != nil {
+				return
+			}
+			rerunScript(ctx, pathToFile, selection, matrix, options, artifactsRoot)
+		}
+	}
+}
+
+// scanScripts returns the modification time of every script kept by the selection
+func scanScripts(ctx context.Context, scriptFolder string, selection *casperjs.Selection) map[string]time.Time {
+
+	modTimes := make(map[string]time.Time)
+	casperjs.WalkScripts(ctx, scriptFolder, nil, func(pathToFile string, info os.FileInfo) {
+		modTimes[pathToFile] = info.ModTime()
+	})
+
+	// The path globs are applied here rather than by WalkScripts, so that
+	// skipped files are not logged again on every poll
+	for pathToFile := range modTimes {
+		if !selection.KeepsPath(scriptFolder, pathToFile) {
+			delete(modTimes, pathToFile)
+		}
+	}
+	return modTimes
+}
+
+// rerunScript loads the script at pathToFile again, and runs it if it still holds
+// a valid and selected Casper test, once per cell of the matrix. Manifest problems are
+// logged right away. The artifacts of the re-run go to a run directory of their own under artifactsRoot.
+func rerunScript(ctx context.Context, pathToFile string, selection *casperjs.Selection, matrix *casperjs.Matrix,
+	options *casperjs.RunOptions, artifactsRoot string) {
+
+	log.Println("----------------------------------------")
+	log.Println("Change detected in: ", pathToFile)
+
+	for _, problem := range casperjs.ValidateScript(pathToFile).Problems {
+		log.Println("Validation problem: ", problem)
+	}
+
+	testScript, err := casperjs.LoadScript(pathToFile)
+	if err != nil {
+		log.Println("Not running ", pathToFile, ": ", err)
+		return
+	}
+	if reason := selection.SkipReason(testScript); reason != "" {
+		log.Println("Not running ", pathToFile, ": ", reason)
+		return
+	}
+
+	rerunOptions := *options
+	if artifactsRoot != "" {
+		artifactsDir, err := casperjs.NewArtifactsRunDir(artifactsRoot, time.Now())
+		if err != nil {
+			log.Println("Not running ", pathToFile, ": error creating the artifacts directory: ", err)
+			return
+		}
+		rerunOptions.ArtifactsDir = artifactsDir
+		log.Println("Collecting artifacts in: ", artifactsDir)
+	}
+
+	tests := casperjs.ExpandMatrix([]*casperjs.CasperTest{testScript}, matrix)
+	casperjs.RunTests(ctx, tests, &rerunOptions)
+	printSummary(os.Stdout, tests)
+}